@@ -0,0 +1,376 @@
+// Package audit records mutations made through the manager as an append-only
+// JSON lines log with size based rotation.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	currentFile   = "audit.log"
+	rotatedPrefix = "audit-"
+	rotatedSuffix = ".log"
+	// maxLineSize bounds a single entry when reading logs back
+	maxLineSize = 4 * 1024 * 1024
+)
+
+// Entry is a single audited mutation
+type Entry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user,omitempty"`
+	RemoteAddr string    `json:"remoteAddr"`
+	ConnID     string    `json:"connId,omitempty"`
+	Operation  string    `json:"operation"`
+	// Keys lists the affected keys. Count is the number of keys changed when
+	// it differs from len(Keys), e.g. for truncated lists or subtree deletes.
+	Keys  []string `json:"keys,omitempty"`
+	Count int      `json:"count,omitempty"`
+	// Target names a non-key object, such as a lease, member, connection or user
+	Target string `json:"target,omitempty"`
+	// OldHash and NewHash are SHA-256 hashes of a single key's value before and after
+	OldHash  string `json:"oldHash,omitempty"`
+	NewHash  string `json:"newHash,omitempty"`
+	Revision int64  `json:"revision,omitempty"`
+}
+
+// Filter selects entries in Query. Zero values match everything.
+type Filter struct {
+	ConnID    string
+	Prefix    string
+	Operation string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+	// WithRevision only matches entries that recorded an etcd revision
+	WithRevision bool
+}
+
+func (f Filter) match(e Entry) bool {
+	if f.ConnID != "" && e.ConnID != f.ConnID {
+		return false
+	}
+	if f.Operation != "" && e.Operation != f.Operation {
+		return false
+	}
+	if f.WithRevision && e.Revision == 0 {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.Prefix != "" {
+		for _, k := range e.Keys {
+			if strings.HasPrefix(k, f.Prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// HashValue returns the hex SHA-256 of a value, as stored in OldHash/NewHash
+func HashValue(value []byte) string {
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log is an append-only audit log. A nil *Log discards entries.
+type Log struct {
+	dir        string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Rotation defaults, used when New is given a size or count of zero or less
+const (
+	DefaultMaxSize    = 100 << 20
+	DefaultMaxBackups = 10
+)
+
+// New opens the audit log in dir. The current file is rotated once it would
+// exceed maxSize bytes; at most maxBackups rotated files are kept.
+func New(dir string, maxSize int64, maxBackups int) (*Log, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = DefaultMaxBackups
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit directory: %w", err)
+	}
+	l := &Log{dir: dir, maxSize: maxSize, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(filepath.Join(l.dir, currentFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat audit log: %w", err)
+	}
+	l.f, l.size = f, st.Size()
+	return nil
+}
+
+// Append writes e as one JSON line, setting Time when it is zero
+func (l *Log) Append(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// rotate renames the current file and prunes old backups. Callers must hold l.mu.
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("failed to close audit log: %w", err)
+	}
+	l.f = nil
+	name := rotatedPrefix + time.Now().UTC().Format("20060102T150405.000000000") + rotatedSuffix
+	if err := os.Rename(filepath.Join(l.dir, currentFile), filepath.Join(l.dir, name)); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+
+	backups, err := l.rotated()
+	if err != nil {
+		return err
+	}
+	if l.maxBackups > 0 && len(backups) > l.maxBackups {
+		for _, old := range backups[:len(backups)-l.maxBackups] {
+			_ = os.Remove(old)
+		}
+	}
+	return nil
+}
+
+// rotated returns rotated files, oldest first
+func (l *Log) rotated() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(l.dir, rotatedPrefix+"*"+rotatedSuffix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// Query returns entries matching f, newest first, including rotated files.
+// Files are read from the end, so a query with a Limit stops at the newest
+// matches.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	if l == nil {
+		return []Entry{}, nil
+	}
+	files, current, size, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	out := []Entry{}
+	if current != nil {
+		out, err = readFile(current, size, f, out)
+		current.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := len(files) - 1; i >= 0 && !f.full(out); i-- {
+		out, err = readRotated(files[i], f, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (f Filter) full(out []Entry) bool {
+	return f.Limit > 0 && len(out) >= f.Limit
+}
+
+// snapshot lists the rotated files and opens the current one with its size, so
+// Query can read them without holding l.mu. The open file keeps its content
+// when a rotation renames it.
+func (l *Log) snapshot() ([]string, *os.File, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	files, err := l.rotated()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	current, err := os.Open(filepath.Join(l.dir, currentFile))
+	if os.IsNotExist(err) {
+		return files, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return files, current, l.size, nil
+}
+
+// readRotated appends the matches of a rotated file to out. A file pruned
+// since the snapshot has no entries left to return.
+func readRotated(path string, f Filter, out []Entry) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	st, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return readFile(file, st.Size(), f, out)
+}
+
+// readFile appends the entries in the first size bytes of r that match f to
+// out, newest first, until out holds f.Limit entries
+func readFile(r io.ReaderAt, size int64, f Filter, out []Entry) ([]Entry, error) {
+	sc := newBackwardScanner(r, size)
+	for !f.full(out) && sc.Scan() {
+		var e Entry
+		// Skip torn or corrupt lines rather than failing the whole query
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out, sc.Err()
+}
+
+// backwardScanner returns the lines of a file, last line first, reading it
+// in chunks from the end
+type backwardScanner struct {
+	r io.ReaderAt
+	// off is where pending starts; the bytes before it are still unread
+	off     int64
+	pending []byte
+	line    []byte
+	done    bool
+	err     error
+}
+
+const scanChunkSize = 64 * 1024
+
+func newBackwardScanner(r io.ReaderAt, size int64) *backwardScanner {
+	return &backwardScanner{r: r, off: size}
+}
+
+// Scan advances to the previous non-empty line
+func (s *backwardScanner) Scan() bool {
+	for !s.done && s.err == nil {
+		if i := bytes.LastIndexByte(s.pending, '\n'); i >= 0 {
+			s.line, s.pending = s.pending[i+1:], s.pending[:i]
+		} else if s.off == 0 {
+			s.line, s.pending, s.done = s.pending, nil, true
+		} else {
+			s.fill()
+			continue
+		}
+		if len(s.line) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fill prepends the chunk before pending
+func (s *backwardScanner) fill() {
+	if len(s.pending) >= maxLineSize {
+		s.err = bufio.ErrTooLong
+		return
+	}
+	n := int64(scanChunkSize)
+	if n > s.off {
+		n = s.off
+	}
+	buf := make([]byte, n, n+int64(len(s.pending)))
+	if _, err := s.r.ReadAt(buf, s.off-n); err != nil {
+		s.err = err
+		return
+	}
+	s.pending = append(buf, s.pending...)
+	s.off -= n
+}
+
+// Bytes returns the current line. It is valid until the next call to Scan.
+func (s *backwardScanner) Bytes() []byte {
+	return s.line
+}
+
+func (s *backwardScanner) Err() error {
+	return s.err
+}
+
+// Close closes the current log file
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
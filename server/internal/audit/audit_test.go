@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendQueryAcrossRotation(t *testing.T) {
+	dir := t.TempDir()
+	// Small enough that every entry rotates the file
+	l, err := New(dir, 200, 3)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		e := Entry{
+			Time:      base.Add(time.Duration(i) * time.Minute),
+			ConnID:    "c1",
+			Operation: "put",
+			Keys:      []string{"/app/" + string(rune('a'+i))},
+			Revision:  int64(i + 1),
+		}
+		if err := l.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	rotated, _ := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	if len(rotated) != 3 {
+		t.Errorf("expected 3 rotated files kept, got %d", len(rotated))
+	}
+
+	all, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	// 3 backups + the current file, one entry each
+	if len(all) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Revision > all[i-1].Revision {
+			t.Errorf("entries not newest first: %d before %d", all[i-1].Revision, all[i].Revision)
+		}
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	// An Audit section missing from the config loads as zeros
+	l, err := New(t.TempDir(), 0, -1)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+	if l.maxSize != DefaultMaxSize || l.maxBackups != DefaultMaxBackups {
+		t.Errorf("maxSize = %d, maxBackups = %d, want %d and %d", l.maxSize, l.maxBackups, DefaultMaxSize, DefaultMaxBackups)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	l, err := New(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, ConnID: "c1", Operation: "put", Keys: []string{"/app/a"}, Revision: 2},
+		{Time: base.Add(time.Hour), ConnID: "c1", Operation: "delete", Keys: []string{"/app/b"}, Revision: 3},
+		{Time: base.Add(2 * time.Hour), ConnID: "c2", Operation: "put", Keys: []string{"/other/c"}},
+		{Time: base.Add(3 * time.Hour), ConnID: "c2", Operation: "user-add", Target: "alice"},
+	}
+	for _, e := range entries {
+		if err := l.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"all", Filter{}, 4},
+		{"connection", Filter{ConnID: "c1"}, 2},
+		{"operation", Filter{Operation: "put"}, 2},
+		{"prefix", Filter{Prefix: "/app/"}, 2},
+		{"since inclusive", Filter{Since: base.Add(time.Hour)}, 3},
+		{"until exclusive", Filter{Until: base.Add(time.Hour)}, 1},
+		{"limit", Filter{Limit: 3}, 3},
+		{"with revision", Filter{WithRevision: true}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d entries, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestBackwardScanner(t *testing.T) {
+	// Lines longer than a chunk are split across reads
+	long := strings.Repeat("x", scanChunkSize+10)
+	content := "first\n\n" + long + "\nlast\ntorn"
+	want := []string{"torn", "last", long, "first"}
+
+	sc := newBackwardScanner(strings.NewReader(content), int64(len(content)))
+	var got []string
+	for sc.Scan() {
+		got = append(got, string(sc.Bytes()))
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d = %.20q, want %.20q", i, got[i], want[i])
+		}
+	}
+
+	// Bytes after size are not part of the snapshot
+	sc = newBackwardScanner(strings.NewReader("a\nb\nc\n"), 4)
+	if !sc.Scan() || string(sc.Bytes()) != "b" {
+		t.Errorf("expected the snapshot to end at b, got %q", sc.Bytes())
+	}
+}
+
+func TestQueryLimitReturnsNewest(t *testing.T) {
+	l, err := New(t.TempDir(), 1000, 5)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+	for i := 1; i <= 50; i++ {
+		if err := l.Append(Entry{Operation: "put", Keys: []string{fmt.Sprintf("/k%d", i)}, Revision: int64(i)}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	got, err := l.Query(Filter{Limit: 15})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(got) != 15 {
+		t.Fatalf("got %d entries, want 15", len(got))
+	}
+	for i, e := range got {
+		if e.Revision != int64(50-i) {
+			t.Errorf("entry %d has revision %d, want %d", i, e.Revision, 50-i)
+		}
+	}
+}
+
+func TestQueryDuringRotation(t *testing.T) {
+	l, err := New(t.TempDir(), 500, 3)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 200; i++ {
+			_ = l.Append(Entry{Operation: "put", Revision: int64(i)})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		got, err := l.Query(Filter{})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		for j := 1; j < len(got); j++ {
+			if got[j].Revision >= got[j-1].Revision {
+				t.Fatalf("entries not newest first: %d after %d", got[j].Revision, got[j-1].Revision)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+func TestNilLogDiscards(t *testing.T) {
+	var l *Log
+	if err := l.Append(Entry{Operation: "put"}); err != nil {
+		t.Errorf("Append on nil log returned %v", err)
+	}
+	got, err := l.Query(Filter{})
+	if err != nil || len(got) != 0 {
+		t.Errorf("Query on nil log = %v, %v", got, err)
+	}
+}
+
+func TestFilePermissions(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(dir, 0, 0)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer l.Close()
+	st, err := os.Stat(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if st.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v", st.Mode().Perm())
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrInvalidConfirmation = errors.New("invalid or expired confirmation token")
+
+// Confirmations issues single-use tokens that confirm a destructive action
+// after its preview. A token is bound to the subject it was issued for and
+// carries the previewed parameters, so exactly what was shown is applied.
+type Confirmations struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	pending map[string]confirmation
+}
+
+type confirmation struct {
+	subject string
+	payload interface{}
+	expires time.Time
+}
+
+func NewConfirmations(ttl time.Duration) *Confirmations {
+	return &Confirmations{ttl: ttl, pending: map[string]confirmation{}}
+}
+
+// Issue stores payload for subject and returns the token that releases it
+func (c *Confirmations) Issue(subject string, payload interface{}) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+	now := time.Now()
+	exp := now.Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, t)
+		}
+	}
+	c.pending[token] = confirmation{subject: subject, payload: payload, expires: exp}
+	return token, exp, nil
+}
+
+// Consume returns the payload of a token issued for subject. A token can be
+// consumed once; presenting it for another subject does not use it up.
+func (c *Confirmations) Consume(token, subject string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[token]
+	if !ok || p.subject != subject {
+		return nil, ErrInvalidConfirmation
+	}
+	delete(c.pending, token)
+	if time.Now().After(p.expires) {
+		return nil, ErrInvalidConfirmation
+	}
+	return p.payload, nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfirmationsSingleUse(t *testing.T) {
+	c := NewConfirmations(time.Minute)
+	token, _, err := c.Issue("alice|compact|c1", 42)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	if _, err := c.Consume(token, "bob|compact|c1"); !errors.Is(err, ErrInvalidConfirmation) {
+		t.Errorf("expected ErrInvalidConfirmation for another subject, got %v", err)
+	}
+	payload, err := c.Consume(token, "alice|compact|c1")
+	if err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if payload.(int) != 42 {
+		t.Errorf("payload = %v, want 42", payload)
+	}
+	if _, err := c.Consume(token, "alice|compact|c1"); !errors.Is(err, ErrInvalidConfirmation) {
+		t.Errorf("expected a consumed token to be rejected, got %v", err)
+	}
+}
+
+func TestConfirmationsExpire(t *testing.T) {
+	c := NewConfirmations(-time.Second)
+	token, _, err := c.Issue("s", nil)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if _, err := c.Consume(token, "s"); !errors.Is(err, ErrInvalidConfirmation) {
+		t.Errorf("expected an expired token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"etcd-manager/server/internal/model"
+)
+
+// CookieName is the session cookie set on login. Browsers cannot attach an
+// Authorization header to EventSource requests, so the cookie is accepted too.
+const CookieName = "etcd_manager_token"
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the JWT claims of a session token
+type Claims struct {
+	Username string `json:"username"`
+	Version  int    `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+// Tokens issues and verifies HS256 session tokens
+type Tokens struct {
+	secret []byte
+	expire time.Duration
+}
+
+func NewTokens(secret []byte, expire time.Duration) *Tokens {
+	return &Tokens{secret: secret, expire: expire}
+}
+
+// Issue returns a signed token for user and its expiry time
+func (t *Tokens) Issue(user model.User) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(t.expire)
+	claims := Claims{
+		Username: user.Username,
+		Version:  user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.Username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, exp, nil
+}
+
+// Parse verifies a token and returns its claims
+func (t *Tokens) Parse(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(tok *jwt.Token) (interface{}, error) {
+		if tok.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", tok.Header["alg"])
+		}
+		return t.secret, nil
+	})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// TokenFromRequest extracts a bearer token from the Authorization header or the session cookie
+func TokenFromRequest(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimPrefix(h, "Bearer ")
+	}
+	if c, err := r.Cookie(CookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+type userKey struct{}
+
+// WithUser stores the authenticated user in ctx
+func WithUser(ctx context.Context, user model.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the authenticated user of a request context
+func UserFrom(ctx context.Context) (model.User, bool) {
+	user, ok := ctx.Value(userKey{}).(model.User)
+	return user, ok
+}
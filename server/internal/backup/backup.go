@@ -0,0 +1,313 @@
+// Package backup takes periodic etcd snapshots of configured connections and
+// keeps the newest of them, with their SHA-256 checksums, in a manifest.
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+
+	"etcd-manager/server/internal/model"
+)
+
+const (
+	manifestFile = "manifest.json"
+	// checkInterval is how often the scheduler looks for due backups
+	checkInterval = time.Minute
+	// snapshotTimeout bounds a single snapshot download
+	snapshotTimeout = 30 * time.Minute
+)
+
+// Entry describes one stored snapshot
+type Entry struct {
+	ConnID   string `json:"connId"`
+	ConnName string `json:"connName"`
+	// File is relative to the backup directory
+	File   string    `json:"file"`
+	Time   time.Time `json:"time"`
+	Size   int64     `json:"size"`
+	SHA256 string    `json:"sha256"`
+}
+
+// Source streams a snapshot of a connection; *etcd.Manager implements it
+type Source interface {
+	Snapshot(ctx context.Context, connID, endpoint string) (io.ReadCloser, error)
+}
+
+// Scheduler snapshots the target connections every interval. A nil
+// *Scheduler lists no backups.
+type Scheduler struct {
+	dir       string
+	source    Source
+	store     *model.ConnectionStore
+	targets   []string
+	interval  time.Duration
+	retention int
+
+	// running serializes backups; mu guards the manifest
+	running sync.Mutex
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New creates a scheduler storing snapshots in dir. targets name connections
+// by id or name; each keeps its newest retention snapshots.
+func New(dir string, source Source, store *model.ConnectionStore, targets []string, interval time.Duration, retention int) (*Scheduler, error) {
+	if interval <= 0 {
+		return nil, errors.New("backup interval must be positive")
+	}
+	if retention < 1 {
+		return nil, errors.New("backup retention must be at least 1")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &Scheduler{
+		dir:       dir,
+		source:    source,
+		store:     store,
+		targets:   targets,
+		interval:  interval,
+		retention: retention,
+	}, nil
+}
+
+// Start runs due backups in the background until Stop. A connection is due
+// when its newest snapshot is older than the interval, so restarts do not
+// delay or repeat backups.
+func (s *Scheduler) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		tick := checkInterval
+		if s.interval < tick {
+			tick = s.interval
+		}
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			s.runDue()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop, waiting for a running backup to finish
+func (s *Scheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// resolve maps the configured targets to stored connections
+func (s *Scheduler) resolve() []model.Connection {
+	conns := s.store.List()
+	var out []model.Connection
+	for _, t := range s.targets {
+		found := false
+		for _, c := range conns {
+			if c.ID == t || c.Name == t {
+				out = append(out, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			logx.Errorf("backup target %q matches no connection", t)
+		}
+	}
+	return out
+}
+
+func (s *Scheduler) runDue() {
+	entries, err := s.List("")
+	if err != nil {
+		logx.Errorf("failed to read backup manifest: %v", err)
+		return
+	}
+	last := map[string]time.Time{}
+	for _, e := range entries {
+		if e.Time.After(last[e.ConnID]) {
+			last[e.ConnID] = e.Time
+		}
+	}
+	now := time.Now()
+	for _, c := range s.resolve() {
+		if now.Sub(last[c.ID]) < s.interval {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+		e, err := s.Run(ctx, c.ID)
+		cancel()
+		if err != nil {
+			logx.Errorf("scheduled backup of connection %q failed: %v", c.Name, err)
+			continue
+		}
+		logx.Infof("backed up connection %q to %s (%d bytes)", c.Name, e.File, e.Size)
+	}
+}
+
+// Run snapshots connection connID now and applies the retention
+func (s *Scheduler) Run(ctx context.Context, connID string) (Entry, error) {
+	conn, ok := s.store.Get(connID)
+	if !ok {
+		return Entry{}, fmt.Errorf("connection %q not found", connID)
+	}
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	now := time.Now().UTC()
+	e := Entry{
+		ConnID:   conn.ID,
+		ConnName: conn.Name,
+		File:     filepath.ToSlash(filepath.Join(conn.ID, now.Format("20060102T150405.000000Z")+".db")),
+		Time:     now,
+	}
+	if err := s.download(ctx, &e); err != nil {
+		return Entry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.readManifest()
+	if err != nil {
+		return Entry{}, err
+	}
+	entries = append(entries, e)
+	entries = s.prune(entries, conn.ID)
+	if err := s.writeManifest(entries); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// download writes the snapshot to e.File through a temporary file, filling
+// in its size and checksum
+func (s *Scheduler) download(ctx context.Context, e *Entry) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(e.File))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	rc, err := s.source.Snapshot(ctx, e.ConnID, "")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp := path + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), rc)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	e.Size = n
+	e.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// prune removes the snapshots of connID beyond the retention, oldest first
+func (s *Scheduler) prune(entries []Entry, connID string) []Entry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.After(entries[j].Time) })
+	kept := entries[:0]
+	n := 0
+	for _, e := range entries {
+		if e.ConnID == connID {
+			n++
+			if n > s.retention {
+				if err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(e.File))); err != nil && !os.IsNotExist(err) {
+					logx.Errorf("failed to remove old backup %s: %v", e.File, err)
+				}
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// List returns the stored snapshots, newest first, of connID or of every
+// connection when connID is empty
+func (s *Scheduler) List(connID string) ([]Entry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	out := []Entry{}
+	for _, e := range entries {
+		if connID == "" || e.ConnID == connID {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	return out, nil
+}
+
+func (s *Scheduler) readManifest() ([]Entry, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, manifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *Scheduler) writeManifest(entries []Entry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, manifestFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
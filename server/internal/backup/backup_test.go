@@ -0,0 +1,99 @@
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"etcd-manager/server/internal/model"
+)
+
+// fakeSource returns a distinct payload for every snapshot
+type fakeSource struct {
+	n int
+}
+
+func (f *fakeSource) Snapshot(ctx context.Context, connID, endpoint string) (io.ReadCloser, error) {
+	f.n++
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("snapshot %d of %s", f.n, connID))), nil
+}
+
+func newTestScheduler(t *testing.T, retention int) (*Scheduler, model.Connection, string) {
+	t.Helper()
+	dir := t.TempDir()
+	store := model.NewConnectionStore(filepath.Join(dir, "connections.json"), make([]byte, 32))
+	conn, err := store.Add("prod", []string{"127.0.0.1:2379"}, "", "")
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	backupDir := filepath.Join(dir, "backups")
+	s, err := New(backupDir, &fakeSource{}, store, []string{"prod"}, time.Hour, retention)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return s, conn, backupDir
+}
+
+func TestRunKeepsRetention(t *testing.T) {
+	s, conn, dir := newTestScheduler(t, 2)
+	var made []Entry
+	for i := 0; i < 3; i++ {
+		e, err := s.Run(context.Background(), conn.ID)
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		made = append(made, e)
+	}
+
+	entries, err := s.List(conn.ID)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].File != made[2].File || entries[1].File != made[1].File {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, made[0].File)); !os.IsNotExist(err) {
+		t.Errorf("expected the oldest snapshot to be removed, got %v", err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, e.File))
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		sum := sha256.Sum256(data)
+		if e.SHA256 != hex.EncodeToString(sum[:]) || e.Size != int64(len(data)) {
+			t.Errorf("manifest does not match %s: %+v", e.File, e)
+		}
+		if e.ConnName != "prod" {
+			t.Errorf("ConnName = %q, want prod", e.ConnName)
+		}
+	}
+}
+
+func TestRunDueSkipsRecentBackups(t *testing.T) {
+	s, conn, _ := newTestScheduler(t, 5)
+	s.runDue()
+	s.runDue()
+	entries, err := s.List(conn.ID)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected one backup within the interval, got %d", len(entries))
+	}
+}
+
+func TestNilSchedulerLists(t *testing.T) {
+	var s *Scheduler
+	entries, err := s.List("")
+	if err != nil || entries != nil {
+		t.Errorf("List on nil = %v, %v", entries, err)
+	}
+}
@@ -15,4 +15,58 @@ type Config struct {
     SecretKey string `json:"secretKey,omitempty"`
     // Allow CORS origins, comma-separated
     CorsOrigins []string `json:"corsOrigins,omitempty"`
-}
\ No newline at end of file
+    // Built-in login and role-based access control
+    Auth AuthConf `json:"auth,optional"`
+    // Audit log of mutations, stored under DataPath/audit
+    Audit AuditConf `json:"audit,optional"`
+    // Scheduled snapshots, stored under DataPath/backups
+    Backup BackupConf `json:"backup,optional"`
+    // Background probing and reconnecting of active connections
+    Health HealthConf `json:"health,optional"`
+}
+
+// AuthConf configures UI authentication.
+type AuthConf struct {
+    // Disable login entirely; only for trusted networks
+    Disabled bool `json:"disabled,optional"`
+    // Secret for signing session tokens (base64). Derived from SecretKey if empty
+    AccessSecret string `json:"accessSecret,optional"`
+    // Session lifetime in seconds
+    AccessExpire int64 `json:"accessExpire,default=86400"`
+    // Password for the initial admin user, created when no users exist.
+    // Falls back to ETCD_MANAGER_ADMIN_PASSWORD or a generated password
+    AdminPassword string `json:"adminPassword,optional"`
+}
+// AuditConf configures the audit log.
+type AuditConf struct {
+    Disabled bool `json:"disabled,optional"`
+    // Rotate the log file once it reaches this size in megabytes
+    MaxSizeMB int64 `json:"maxSizeMB,default=100"`
+    // Number of rotated files to keep
+    MaxBackups int `json:"maxBackups,default=10"`
+}
+
+// BackupConf configures scheduled snapshots.
+type BackupConf struct {
+    // Connections to back up, by id or name; none disables the scheduler
+    Connections []string `json:"connections,optional"`
+    // Seconds between snapshots of a connection
+    Interval int64 `json:"interval,default=86400"`
+    // Number of snapshots kept per connection
+    Retention int `json:"retention,default=7"`
+}
+
+// HealthConf configures the health monitor of active connections.
+type HealthConf struct {
+    // Seconds between probes of a healthy connection
+    Interval int64 `json:"interval,default=15"`
+    // Seconds before a probe times out
+    Timeout int64 `json:"timeout,default=3"`
+    // Upper bound in seconds of the delay between reconnect attempts
+    MaxBackoff int64 `json:"maxBackoff,default=300"`
+    // Reads slower than this many milliseconds mark a connection degraded
+    SlowMs int64 `json:"slowMs,default=1000"`
+    // Do not reconnect at startup the connections left active by the
+    // previous run; their saved statuses are reset to disconnected instead
+    DisableRestore bool `json:"disableRestore,optional"`
+}
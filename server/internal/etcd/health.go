@@ -0,0 +1,347 @@
+package etcd
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/zeromicro/go-zero/core/logx"
+    clientv3 "go.etcd.io/etcd/client/v3"
+
+    "etcd-manager/server/internal/model"
+)
+
+// MonitorConfig tunes the background health monitor
+type MonitorConfig struct {
+    // Interval between probes of a healthy connection
+    Interval time.Duration
+    // Timeout of a single probe
+    Timeout time.Duration
+    // MaxBackoff caps the delay between reconnect attempts
+    MaxBackoff time.Duration
+    // SlowLatency marks a connection degraded when a read takes longer
+    SlowLatency time.Duration
+}
+
+// EndpointHealth is the probe result of one endpoint
+type EndpointHealth struct {
+    Endpoint  string `json:"endpoint"`
+    Healthy   bool   `json:"healthy"`
+    LatencyMs int64  `json:"latencyMs"`
+    Error     string `json:"error,omitempty"`
+}
+
+// Health is the latest known state of a connection
+type Health struct {
+    Status    string
+    LastError string
+    // LastProbe is the time of the last probe, LastHealthy of the last successful one
+    LastProbe   time.Time
+    LastHealthy time.Time
+    Latency     time.Duration
+    Endpoints   []EndpointHealth
+    // Failures counts consecutive failed probes or reconnects
+    Failures  int
+    NextProbe time.Time
+}
+
+// Health returns the monitored state of connection id
+func (m *Manager) Health(id string) (Health, bool) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    h, ok := m.health[id]
+    if !ok {
+        return Health{}, false
+    }
+    out := *h
+    out.Endpoints = append([]EndpointHealth(nil), h.Endpoints...)
+    return out, true
+}
+
+// StartMonitor probes active connections in the background and reconnects
+// failed ones with exponential backoff, until StopMonitor is called. Zero
+// fields of cfg take their DefaultMonitorConfig values.
+func (m *Manager) StartMonitor(cfg MonitorConfig) {
+    cfg = cfg.withDefaults()
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.stopMonitor != nil {
+        return
+    }
+    m.monitor = cfg
+    stop := make(chan struct{})
+    m.stopMonitor = stop
+    go func() {
+        ticker := time.NewTicker(monitorTick(cfg.Interval))
+        defer ticker.Stop()
+        for {
+            select {
+            case <-stop:
+                return
+            case now := <-ticker.C:
+                m.checkAll(now)
+            }
+        }
+    }()
+}
+
+// StopMonitor stops the background health monitor
+func (m *Manager) StopMonitor() {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.stopMonitor != nil {
+        close(m.stopMonitor)
+        m.stopMonitor = nil
+    }
+}
+
+func (c MonitorConfig) withDefaults() MonitorConfig {
+    if c.Interval <= 0 {
+        c.Interval = DefaultMonitorConfig.Interval
+    }
+    if c.Timeout <= 0 {
+        c.Timeout = DefaultMonitorConfig.Timeout
+    }
+    if c.MaxBackoff <= 0 {
+        c.MaxBackoff = DefaultMonitorConfig.MaxBackoff
+    }
+    if c.SlowLatency <= 0 {
+        c.SlowLatency = DefaultMonitorConfig.SlowLatency
+    }
+    return c
+}
+
+func (m *Manager) monitorConfig() MonitorConfig {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return m.monitor
+}
+
+// monitorTick is how often due connections are looked for; short enough that
+// reconnect backoff is honoured closely
+func monitorTick(interval time.Duration) time.Duration {
+    if interval <= 0 || interval > 5*time.Second {
+        return time.Second
+    }
+    return interval / 5
+}
+
+// backoff returns the delay before the next reconnect after failures
+// consecutive failures: interval doubled per failure, capped at max
+func backoff(interval, max time.Duration, failures int) time.Duration {
+    d := interval
+    for i := 1; i < failures && d < max; i++ {
+        d *= 2
+    }
+    if max > 0 && d > max {
+        d = max
+    }
+    return d
+}
+
+// checkAll probes, or reconnects, every connection that is due
+func (m *Manager) checkAll(now time.Time) {
+    m.mu.Lock()
+    var due []string
+    for id := range m.wanted {
+        if m.checking[id] {
+            continue
+        }
+        if h, ok := m.health[id]; !ok || !now.Before(h.NextProbe) {
+            due = append(due, id)
+            m.checking[id] = true
+        }
+    }
+    m.mu.Unlock()
+
+    var wg sync.WaitGroup
+    for _, id := range due {
+        wg.Add(1)
+        go func(id string) {
+            defer wg.Done()
+            m.check(id)
+            m.mu.Lock()
+            delete(m.checking, id)
+            m.mu.Unlock()
+        }(id)
+    }
+    wg.Wait()
+}
+
+// check probes the client of connection id. A connection without a client,
+// or that failed its previous probe too, is reconnected instead.
+func (m *Manager) check(id string) {
+    m.mu.RLock()
+    cli, connected := m.clients[id]
+    h, known := m.health[id]
+    failing := known && h.Status == model.StatusError
+    cfg := m.monitor
+    m.mu.RUnlock()
+
+    if connected {
+        result := probe(cli, cfg)
+        // A client that recovers on its own keeps its lease keep-alives
+        if result.err == nil || !failing {
+            m.record(id, result)
+            return
+        }
+    }
+    if err := m.reconnect(id); err != nil {
+        logx.Errorf("reconnecting connection %s failed: %v", id, err)
+    }
+}
+
+// reconnect dials a fresh client for connection id and swaps it in once it
+// answers a probe. Lease keep-alives of the old client stop with it.
+func (m *Manager) reconnect(id string) error {
+    conn, ok := m.store.Get(id)
+    if !ok {
+        m.forget(id)
+        return nil
+    }
+    cli, err := m.dial(conn)
+    if err != nil {
+        m.record(id, probeResult{err: err})
+        return err
+    }
+    result := probe(cli, m.monitorConfig())
+    if result.err != nil {
+        _ = cli.Close()
+        m.record(id, result)
+        return result.err
+    }
+
+    m.mu.Lock()
+    if !m.wanted[id] {
+        // Disconnected while dialing
+        m.mu.Unlock()
+        _ = cli.Close()
+        return nil
+    }
+    m.stopKeepAlives(id)
+    if old, ok := m.clients[id]; ok && old != nil {
+        _ = old.Close()
+    }
+    m.clients[id] = cli
+    m.mu.Unlock()
+
+    logx.Infof("connection %s (%s) reconnected", id, conn.Name)
+    m.record(id, result)
+    return nil
+}
+
+// probeResult is the outcome of one health probe
+type probeResult struct {
+    err       error
+    latency   time.Duration
+    endpoints []EndpointHealth
+    // alarms are raised by the cluster, such as NOSPACE
+    alarms []string
+}
+
+// status classifies a probe: a failed read is an error, while a slow read,
+// an unreachable endpoint or an alarm only degrades the connection
+func (p probeResult) status(slow time.Duration) (string, string) {
+    if p.err != nil {
+        return model.StatusError, p.err.Error()
+    }
+    var problems []string
+    for _, ep := range p.endpoints {
+        if !ep.Healthy {
+            problems = append(problems, fmt.Sprintf("endpoint %s: %s", ep.Endpoint, ep.Error))
+        }
+    }
+    problems = append(problems, p.alarms...)
+    if slow > 0 && p.latency > slow {
+        problems = append(problems, fmt.Sprintf("read took %s", p.latency.Round(time.Millisecond)))
+    }
+    if len(problems) > 0 {
+        return model.StatusDegraded, strings.Join(problems, "; ")
+    }
+    return model.StatusConnected, ""
+}
+
+// probe reads through the cluster and queries the status of every endpoint
+func probe(cli *clientv3.Client, cfg MonitorConfig) probeResult {
+    var result probeResult
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+    start := time.Now()
+    _, err := cli.Get(ctx, "__health__")
+    result.latency = time.Since(start)
+    cancel()
+    if err != nil {
+        result.err = err
+        return result
+    }
+
+    for _, ep := range cli.Endpoints() {
+        eh := EndpointHealth{Endpoint: ep}
+        ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+        start := time.Now()
+        st, err := cli.Status(ctx, ep)
+        cancel()
+        eh.LatencyMs = time.Since(start).Milliseconds()
+        if err != nil {
+            eh.Error = err.Error()
+        } else {
+            eh.Healthy = true
+            for _, e := range st.Errors {
+                result.alarms = append(result.alarms, fmt.Sprintf("endpoint %s: %s", ep, e))
+            }
+        }
+        result.endpoints = append(result.endpoints, eh)
+    }
+    return result
+}
+
+// record stores a probe result, schedules the next probe and persists a
+// changed status
+func (m *Manager) record(id string, result probeResult) {
+    now := time.Now()
+
+    m.mu.Lock()
+    status, msg := result.status(m.monitor.SlowLatency)
+    if !m.wanted[id] {
+        m.mu.Unlock()
+        return
+    }
+    h, ok := m.health[id]
+    if !ok {
+        h = &Health{}
+        m.health[id] = h
+    }
+    changed := h.Status != status
+    h.Status = status
+    h.LastError = msg
+    h.LastProbe = now
+    h.Endpoints = result.endpoints
+    if status == model.StatusError {
+        h.Failures++
+        h.NextProbe = now.Add(backoff(m.monitor.Interval, m.monitor.MaxBackoff, h.Failures))
+    } else {
+        h.Failures = 0
+        h.LastHealthy = now
+        h.Latency = result.latency
+        h.NextProbe = now.Add(m.monitor.Interval)
+    }
+    m.mu.Unlock()
+
+    if changed {
+        if status == model.StatusConnected {
+            logx.Infof("connection %s is healthy", id)
+        } else {
+            logx.Errorf("connection %s status %s: %s", id, status, msg)
+        }
+        _ = m.store.SetStatus(id, status)
+    }
+}
+
+// forget stops monitoring connection id
+func (m *Manager) forget(id string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.wanted, id)
+    delete(m.health, id)
+}
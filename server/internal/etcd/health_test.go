@@ -0,0 +1,54 @@
+package etcd
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "etcd-manager/server/internal/model"
+)
+
+func TestBackoff(t *testing.T) {
+    tests := []struct {
+        failures int
+        want     time.Duration
+    }{
+        {1, 15 * time.Second},
+        {2, 30 * time.Second},
+        {3, time.Minute},
+        {5, 4 * time.Minute},
+        {6, 5 * time.Minute},
+        {50, 5 * time.Minute},
+    }
+    for _, tt := range tests {
+        if got := backoff(15*time.Second, 5*time.Minute, tt.failures); got != tt.want {
+            t.Errorf("backoff(%d) = %s, want %s", tt.failures, got, tt.want)
+        }
+    }
+}
+
+func TestProbeStatus(t *testing.T) {
+    healthy := []EndpointHealth{{Endpoint: "a", Healthy: true}, {Endpoint: "b", Healthy: true}}
+    tests := []struct {
+        name   string
+        result probeResult
+        want   string
+    }{
+        {"healthy", probeResult{latency: time.Millisecond, endpoints: healthy}, model.StatusConnected},
+        {"read failed", probeResult{err: errors.New("context deadline exceeded")}, model.StatusError},
+        {"endpoint down", probeResult{endpoints: []EndpointHealth{{Endpoint: "a", Healthy: true}, {Endpoint: "b", Error: "refused"}}}, model.StatusDegraded},
+        {"alarm", probeResult{endpoints: healthy, alarms: []string{"endpoint a: NOSPACE"}}, model.StatusDegraded},
+        {"slow read", probeResult{latency: 2 * time.Second, endpoints: healthy}, model.StatusDegraded},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            status, msg := tt.result.status(time.Second)
+            if status != tt.want {
+                t.Errorf("status = %s, want %s", status, tt.want)
+            }
+            if (status == model.StatusConnected) != (msg == "") {
+                t.Errorf("unexpected message %q for status %s", msg, status)
+            }
+        })
+    }
+}
@@ -0,0 +1,83 @@
+package etcd
+
+import (
+    "context"
+    "errors"
+
+    clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// keepAlive is a running background lease keep-alive
+type keepAlive struct {
+    cancel context.CancelFunc
+}
+
+// StartKeepAlive keeps a lease alive in the background until it is stopped,
+// the lease expires or is revoked, or the connection is closed.
+func (m *Manager) StartKeepAlive(id string, lease clientv3.LeaseID) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    cli, ok := m.clients[id]
+    if !ok {
+        return errors.New("not connected")
+    }
+    if _, running := m.keepAlives[id][lease]; running {
+        return nil
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    ch, err := cli.KeepAlive(ctx, lease)
+    if err != nil {
+        cancel()
+        return err
+    }
+    if m.keepAlives[id] == nil {
+        m.keepAlives[id] = make(map[clientv3.LeaseID]*keepAlive)
+    }
+    ka := &keepAlive{cancel: cancel}
+    m.keepAlives[id][lease] = ka
+
+    go func() {
+        // Drain responses; the channel closes when the lease is gone or ctx is cancelled
+        for range ch {
+        }
+        cancel()
+        m.mu.Lock()
+        defer m.mu.Unlock()
+        if m.keepAlives[id][lease] == ka {
+            delete(m.keepAlives[id], lease)
+        }
+    }()
+    return nil
+}
+
+// StopKeepAlive stops a background keep-alive. It reports whether one was running.
+func (m *Manager) StopKeepAlive(id string, lease clientv3.LeaseID) bool {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    ka, ok := m.keepAlives[id][lease]
+    if ok {
+        ka.cancel()
+        delete(m.keepAlives[id], lease)
+    }
+    return ok
+}
+
+// KeepAlives returns the leases with a running background keep-alive.
+func (m *Manager) KeepAlives(id string) map[clientv3.LeaseID]bool {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    out := make(map[clientv3.LeaseID]bool, len(m.keepAlives[id]))
+    for lease := range m.keepAlives[id] {
+        out[lease] = true
+    }
+    return out
+}
+
+// stopKeepAlives cancels every keep-alive of a connection. Callers must hold m.mu.
+func (m *Manager) stopKeepAlives(id string) {
+    for _, ka := range m.keepAlives[id] {
+        ka.cancel()
+    }
+    delete(m.keepAlives, id)
+}
@@ -5,6 +5,7 @@ import (
     "sync"
     "time"
 
+    "github.com/zeromicro/go-zero/core/logx"
     clientv3 "go.etcd.io/etcd/client/v3"
 
     "etcd-manager/server/internal/model"
@@ -12,15 +13,44 @@ import (
 
 type Manager struct {
     store *model.ConnectionStore
+    // certDir holds the TLS files connections refer to
+    certDir string
     // active clients by connection id
     clients map[string]*clientv3.Client
+    // background lease keep-alives by connection id
+    keepAlives map[string]map[clientv3.LeaseID]*keepAlive
+    // connections that should stay connected, whether or not they currently are
+    wanted map[string]bool
+    // latest probe results by connection id
+    health      map[string]*Health
+    // connections being probed or reconnected, so startup restores and
+    // monitor ticks do not overlap
+    checking    map[string]bool
+    monitor     MonitorConfig
+    stopMonitor chan struct{}
     mu      sync.RWMutex
 }
 
-func NewManager(store *model.ConnectionStore) *Manager {
+// DefaultMonitorConfig is used for connecting until StartMonitor is called
+var DefaultMonitorConfig = MonitorConfig{
+    Interval:    15 * time.Second,
+    Timeout:     3 * time.Second,
+    MaxBackoff:  5 * time.Minute,
+    SlowLatency: time.Second,
+}
+
+// NewManager creates a manager for the connections in store. TLS file
+// references of connections are resolved inside certDir.
+func NewManager(store *model.ConnectionStore, certDir string) *Manager {
     return &Manager{
-        store:   store,
-        clients: make(map[string]*clientv3.Client),
+        store:      store,
+        certDir:    certDir,
+        clients:    make(map[string]*clientv3.Client),
+        keepAlives: make(map[string]map[clientv3.LeaseID]*keepAlive),
+        wanted:     make(map[string]bool),
+        health:     make(map[string]*Health),
+        checking:   make(map[string]bool),
+        monitor:    DefaultMonitorConfig,
     }
 }
 
@@ -38,6 +68,9 @@ func (m *Manager) ActiveCount() int {
     return len(m.clients)
 }
 
+// Connect dials connection id and probes it. The connection is monitored
+// from then on: if it fails, now or later, it is reconnected in the
+// background until Disconnect.
 func (m *Manager) Connect(id string) error {
     conn, ok := m.store.Get(id)
     if !ok {
@@ -45,51 +78,102 @@ func (m *Manager) Connect(id string) error {
     }
 
     m.mu.Lock()
+    m.wanted[id] = true
     // close existing
+    m.stopKeepAlives(id)
     if old, ok := m.clients[id]; ok && old != nil {
         _ = old.Close()
         delete(m.clients, id)
     }
     m.mu.Unlock()
 
-    cfg := clientv3.Config{
-        Endpoints:   conn.Endpoints,
-        DialTimeout: 5 * time.Second,
-    }
-    if conn.Username != "" || conn.Password != "" {
-        cfg.Username = conn.Username
-        cfg.Password = conn.Password
-    }
-    cli, err := clientv3.New(cfg)
+    cli, err := m.dial(conn)
     if err != nil {
-        _ = m.store.SetStatus(id, "error")
+        m.record(id, probeResult{err: err})
         return err
     }
-    // quick health check
-    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-    defer cancel()
-    _, err = cli.Get(ctx, "__health__")
-    if err != nil {
+    result := probe(cli, m.monitorConfig())
+    if result.err != nil {
         _ = cli.Close()
-        _ = m.store.SetStatus(id, "error")
-        return err
+        m.record(id, result)
+        return result.err
     }
 
     m.mu.Lock()
     m.clients[id] = cli
     m.mu.Unlock()
 
-    _ = m.store.SetStatus(id, "connected")
+    m.record(id, result)
     return nil
 }
 
+// Restore reconciles the statuses saved by a previous run with the empty set
+// of clients. With reconnect, every connection that was not disconnected is
+// connected again in the background and retried by the monitor until it
+// succeeds; otherwise their statuses are reset to disconnected.
+func (m *Manager) Restore(reconnect bool) {
+    var ids []string
+    for _, conn := range m.store.List() {
+        if conn.Status == "" || conn.Status == model.StatusDisconnected {
+            continue
+        }
+        if !reconnect {
+            _ = m.store.SetStatus(conn.ID, model.StatusDisconnected)
+            continue
+        }
+        ids = append(ids, conn.ID)
+    }
+    if len(ids) == 0 {
+        return
+    }
+
+    m.mu.Lock()
+    for _, id := range ids {
+        m.wanted[id] = true
+    }
+    m.mu.Unlock()
+    logx.Infof("restoring %d connections", len(ids))
+    go m.checkAll(time.Now())
+}
+
+// dial creates a client for a stored connection
+func (m *Manager) dial(conn model.Connection) (*clientv3.Client, error) {
+    cfg, err := m.clientConfig(conn)
+    if err != nil {
+        return nil, err
+    }
+    return clientv3.New(cfg)
+}
+
+// clientConfig builds the client configuration of a stored connection
+func (m *Manager) clientConfig(conn model.Connection) (clientv3.Config, error) {
+    cfg := clientv3.Config{
+        Endpoints:   conn.Endpoints,
+        DialTimeout: 5 * time.Second,
+    }
+    if conn.Username != "" || conn.Password != "" {
+        cfg.Username = conn.Username
+        cfg.Password = conn.Password
+    }
+    if conn.TLS != nil {
+        tlsCfg, err := BuildTLSConfig(conn.TLS, m.certDir)
+        if err != nil {
+            return cfg, err
+        }
+        cfg.TLS = tlsCfg
+    }
+    return cfg, nil
+}
+
 func (m *Manager) Disconnect(id string) error {
+    m.forget(id)
     m.mu.Lock()
+    m.stopKeepAlives(id)
     if cli, ok := m.clients[id]; ok && cli != nil {
         _ = cli.Close()
         delete(m.clients, id)
     }
     m.mu.Unlock()
 
-    return m.store.SetStatus(id, "disconnected")
+    return m.store.SetStatus(id, model.StatusDisconnected)
 }
\ No newline at end of file
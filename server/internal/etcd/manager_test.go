@@ -0,0 +1,76 @@
+package etcd
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+
+    "etcd-manager/server/internal/model"
+)
+
+// newRestoreStore saves one connection per status, pointing at a closed port
+func newRestoreStore(t *testing.T, statuses ...string) (*model.ConnectionStore, []string) {
+    store := model.NewConnectionStore(filepath.Join(t.TempDir(), "connections.json"), make([]byte, 32))
+    var ids []string
+    for _, status := range statuses {
+        conn, err := store.Add(status, []string{"127.0.0.1:1"}, "", "")
+        if err != nil {
+            t.Fatalf("add: %v", err)
+        }
+        if err := store.SetStatus(conn.ID, status); err != nil {
+            t.Fatalf("set status: %v", err)
+        }
+        ids = append(ids, conn.ID)
+    }
+    return store, ids
+}
+
+func TestRestoreResetsStatuses(t *testing.T) {
+    store, ids := newRestoreStore(t, model.StatusConnected, model.StatusError, model.StatusDisconnected)
+    m := NewManager(store, t.TempDir())
+    m.Restore(false)
+
+    for _, id := range ids {
+        conn, _ := store.Get(id)
+        if conn.Status != model.StatusDisconnected {
+            t.Errorf("connection %s status = %s, want %s", conn.Name, conn.Status, model.StatusDisconnected)
+        }
+    }
+    if len(m.wanted) != 0 {
+        t.Errorf("wanted = %v, want none", m.wanted)
+    }
+}
+
+func TestRestoreReconnects(t *testing.T) {
+    store, ids := newRestoreStore(t, model.StatusConnected, model.StatusDisconnected)
+    m := NewManager(store, t.TempDir())
+    m.monitor.Timeout = 50 * time.Millisecond
+    m.Restore(true)
+
+    m.mu.RLock()
+    restored, skipped := m.wanted[ids[0]], m.wanted[ids[1]]
+    m.mu.RUnlock()
+    if !restored || skipped {
+        t.Fatalf("wanted = %v, want only %s", m.wanted, ids[0])
+    }
+
+    // The endpoint is unreachable, so the restore fails and is left to the monitor
+    deadline := time.Now().Add(10 * time.Second)
+    for {
+        h, ok := m.Health(ids[0])
+        if ok && h.Status == model.StatusError {
+            if h.Failures != 1 || h.NextProbe.IsZero() {
+                t.Errorf("failures = %d, next probe %s", h.Failures, h.NextProbe)
+            }
+            break
+        }
+        if time.Now().After(deadline) {
+            t.Fatal("restored connection was not probed")
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+    if conn, _ := store.Get(ids[0]); conn.Status != model.StatusError {
+        t.Errorf("saved status = %s, want %s", conn.Status, model.StatusError)
+    }
+    m.forget(ids[0])
+}
@@ -0,0 +1,48 @@
+package etcd
+
+import (
+    "context"
+    "fmt"
+    "io"
+
+    clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// Snapshot streams a backend snapshot of connection id. It dials a dedicated
+// client so the snapshot is taken from endpoint, or from any endpoint of the
+// connection when endpoint is empty; the connection need not be active.
+func (m *Manager) Snapshot(ctx context.Context, id, endpoint string) (io.ReadCloser, error) {
+    conn, ok := m.store.Get(id)
+    if !ok {
+        return nil, fmt.Errorf("connection %q not found", id)
+    }
+    cfg, err := m.clientConfig(conn)
+    if err != nil {
+        return nil, err
+    }
+    if endpoint != "" {
+        cfg.Endpoints = []string{endpoint}
+    }
+    cli, err := clientv3.New(cfg)
+    if err != nil {
+        return nil, err
+    }
+    rc, err := cli.Snapshot(ctx)
+    if err != nil {
+        _ = cli.Close()
+        return nil, err
+    }
+    return &snapshotReader{ReadCloser: rc, cli: cli}, nil
+}
+
+// snapshotReader closes the dedicated client together with the stream
+type snapshotReader struct {
+    io.ReadCloser
+    cli *clientv3.Client
+}
+
+func (s *snapshotReader) Close() error {
+    err := s.ReadCloser.Close()
+    _ = s.cli.Close()
+    return err
+}
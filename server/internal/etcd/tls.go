@@ -0,0 +1,85 @@
+package etcd
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+
+    "etcd-manager/server/internal/model"
+)
+
+// BuildTLSConfig converts the stored TLS settings of a connection into a tls.Config.
+// Inline PEM content takes precedence over file references, which are read
+// from certDir.
+func BuildTLSConfig(t *model.TLSConfig, certDir string) (*tls.Config, error) {
+    if t == nil {
+        return nil, nil
+    }
+
+    cfg := &tls.Config{
+        MinVersion:         tls.VersionTLS12,
+        ServerName:         t.ServerName,
+        InsecureSkipVerify: t.InsecureSkipVerify,
+    }
+
+    caPEM, err := pemContent(t.CACert, certDir, t.CACertFile)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+    }
+    if len(caPEM) > 0 {
+        pool := x509.NewCertPool()
+        if !pool.AppendCertsFromPEM(caPEM) {
+            return nil, errors.New("CA bundle contains no valid certificates")
+        }
+        cfg.RootCAs = pool
+    }
+
+    certPEM, err := pemContent(t.ClientCert, certDir, t.ClientCertFile)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read client certificate: %w", err)
+    }
+    keyPEM, err := pemContent(t.ClientKey, certDir, t.ClientKeyFile)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read client key: %w", err)
+    }
+    if len(certPEM) > 0 || len(keyPEM) > 0 {
+        if len(certPEM) == 0 || len(keyPEM) == 0 {
+            return nil, errors.New("client certificate and key must be provided together")
+        }
+        pair, err := tls.X509KeyPair(certPEM, keyPEM)
+        if err != nil {
+            return nil, fmt.Errorf("invalid client certificate/key pair: %w", err)
+        }
+        cfg.Certificates = []tls.Certificate{pair}
+    }
+
+    return cfg, nil
+}
+
+// pemContent returns the inline PEM if set, otherwise reads the file name
+// under certDir.
+func pemContent(inline, certDir, name string) ([]byte, error) {
+    if inline != "" {
+        return []byte(inline), nil
+    }
+    if name == "" {
+        return nil, nil
+    }
+    if err := ValidateCertFile(name); err != nil {
+        return nil, err
+    }
+    return os.ReadFile(filepath.Join(certDir, name))
+}
+
+// ValidateCertFile checks a TLS file reference. References are paths relative
+// to the certificate directory and cannot leave it, so a connection cannot be
+// used to read other files on the server.
+func ValidateCertFile(name string) error {
+    if !filepath.IsLocal(name) {
+        return fmt.Errorf("%q must be a relative path inside the certificate directory", name)
+    }
+    return nil
+}
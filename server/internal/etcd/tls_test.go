@@ -0,0 +1,109 @@
+package etcd
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "math/big"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "etcd-manager/server/internal/model"
+)
+
+// selfSignedPEM returns a PEM-encoded self-signed certificate and its private key
+func selfSignedPEM(t *testing.T) (string, string) {
+    t.Helper()
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil {
+        t.Fatalf("failed to generate key: %v", err)
+    }
+    tmpl := &x509.Certificate{
+        SerialNumber:          big.NewInt(1),
+        Subject:               pkix.Name{CommonName: "etcd-test"},
+        NotBefore:             time.Now().Add(-time.Hour),
+        NotAfter:              time.Now().Add(time.Hour),
+        IsCA:                  true,
+        BasicConstraintsValid: true,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil {
+        t.Fatalf("failed to create certificate: %v", err)
+    }
+    keyDER, err := x509.MarshalECPrivateKey(key)
+    if err != nil {
+        t.Fatalf("failed to marshal key: %v", err)
+    }
+    certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+    keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+    return string(certPEM), string(keyPEM)
+}
+
+// TestBuildTLSConfig verifies inline and file-based TLS material is loaded
+func TestBuildTLSConfig(t *testing.T) {
+    certPEM, keyPEM := selfSignedPEM(t)
+
+    dir := t.TempDir()
+    if err := os.MkdirAll(filepath.Join(dir, "prod"), 0700); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "prod", "client-key.pem"), []byte(keyPEM), 0600); err != nil {
+        t.Fatalf("failed to write key file: %v", err)
+    }
+
+    cfg, err := BuildTLSConfig(&model.TLSConfig{
+        CACert:        certPEM,
+        ClientCert:    certPEM,
+        ClientKeyFile: "prod/client-key.pem",
+        ServerName:    "etcd.internal",
+    }, dir)
+    if err != nil {
+        t.Fatalf("BuildTLSConfig failed: %v", err)
+    }
+    if cfg.RootCAs == nil {
+        t.Error("expected RootCAs to be set")
+    }
+    if len(cfg.Certificates) != 1 {
+        t.Errorf("expected 1 client certificate, got %d", len(cfg.Certificates))
+    }
+    if cfg.ServerName != "etcd.internal" {
+        t.Errorf("expected server name override, got %q", cfg.ServerName)
+    }
+}
+
+// TestBuildTLSConfigErrors verifies invalid TLS material is rejected
+func TestBuildTLSConfigErrors(t *testing.T) {
+    certPEM, _ := selfSignedPEM(t)
+
+    tests := []struct {
+        name     string
+        tls      *model.TLSConfig
+        errorMsg string
+    }{
+        {"cert without key", &model.TLSConfig{ClientCert: certPEM}, "must be provided together"},
+        {"garbage CA", &model.TLSConfig{CACert: "garbage"}, "no valid certificates"},
+        {"missing CA file", &model.TLSConfig{CACertFile: "nonexistent/ca.pem"}, "failed to read CA bundle"},
+        {"absolute file", &model.TLSConfig{CACertFile: "/etc/passwd"}, "inside the certificate directory"},
+        {"file outside the directory", &model.TLSConfig{ClientKeyFile: "../users.json"}, "inside the certificate directory"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, err := BuildTLSConfig(tt.tls, t.TempDir())
+            if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+                t.Errorf("expected error containing %q, got %v", tt.errorMsg, err)
+            }
+        })
+    }
+
+    cfg, err := BuildTLSConfig(nil, "")
+    if cfg != nil || err != nil {
+        t.Errorf("nil config should produce no TLS, got %v, %v", cfg, err)
+    }
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/auth"
+	"etcd-manager/server/internal/svc"
+)
+
+// Audited operations
+const (
+	opPut                = "put"
+	opCreate             = "create"
+	opDelete             = "delete"
+	opBatchDelete        = "batch-delete"
+	opTxn                = "txn"
+	opRename             = "rename"
+	opCopy               = "copy"
+	opImport             = "import"
+	opSync               = "sync"
+	opRollback           = "rollback"
+	opRestore            = "restore"
+	opLeaseGrant         = "lease-grant"
+	opLeaseRevoke        = "lease-revoke"
+	opLeaseKeepAliveStop = "lease-keepalive-stop"
+	opMemberAdd          = "member-add"
+	opMemberRemove       = "member-remove"
+	opMemberPromote      = "member-promote"
+	opConnectionAdd      = "connection-add"
+	opConnectionUpdate   = "connection-update"
+	opConnectionDelete   = "connection-delete"
+	opUserAdd            = "user-add"
+	opUserUpdate         = "user-update"
+	opUserDelete         = "user-delete"
+	opSchemaAdd          = "schema-add"
+	opSchemaUpdate       = "schema-update"
+	opSchemaDelete       = "schema-delete"
+	opCompact            = "compact"
+	opDefragment         = "defragment"
+)
+
+const (
+	// maxAuditKeys caps the keys stored in one entry; Count keeps the total
+	maxAuditKeys      = 100
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type auditReq struct {
+	ConnID    string `form:"connId,optional"`
+	Prefix    string `form:"prefix,optional"`
+	Operation string `form:"operation,optional"`
+	// Since and Until are RFC 3339 timestamps; Until is exclusive
+	Since string `form:"since,optional"`
+	Until string `form:"until,optional"`
+	Limit int    `form:"limit,optional"`
+}
+
+// recordAudit completes e with the caller's identity and appends it to the
+// audit log. Failures are logged but never fail the request that already ran.
+func recordAudit(ctx *svc.ServiceContext, r *http.Request, e audit.Entry) {
+	if ctx.Audit == nil {
+		return
+	}
+	if user, ok := auth.UserFrom(r.Context()); ok {
+		e.User = user.Username
+	}
+	e.RemoteAddr = httpx.GetRemoteAddr(r)
+	if len(e.Keys) > maxAuditKeys {
+		if e.Count == 0 {
+			e.Count = len(e.Keys)
+		}
+		e.Keys = e.Keys[:maxAuditKeys]
+	}
+	if err := ctx.Audit.Append(e); err != nil {
+		logx.Errorf("failed to write audit entry for %s: %v", e.Operation, err)
+	}
+}
+
+// auditBulk records the keys a recursive rename, copy or import has written so far
+func auditBulk(ctx *svc.ServiceContext, r *http.Request, op, connID string, resp bulkResp) {
+	if resp.Succeeded == 0 {
+		return
+	}
+	var keys []string
+	for _, res := range resp.Results {
+		switch res.Status {
+		case bulkStatusMoved:
+			keys = append(keys, res.From, res.To)
+		case bulkStatusCopied, bulkStatusImported:
+			keys = append(keys, res.To)
+		}
+	}
+	recordAudit(ctx, r, audit.Entry{
+		ConnID:    connID,
+		Operation: op,
+		Keys:      keys,
+		Count:     resp.Succeeded,
+		Revision:  resp.Revision,
+	})
+}
+
+func queryAudit(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req auditReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+
+		f := audit.Filter{
+			ConnID:    req.ConnID,
+			Prefix:    req.Prefix,
+			Operation: req.Operation,
+			Limit:     req.Limit,
+		}
+		if f.Limit <= 0 {
+			f.Limit = defaultAuditLimit
+		}
+		if f.Limit > maxAuditLimit {
+			f.Limit = maxAuditLimit
+		}
+		var err error
+		if req.Since != "" {
+			if f.Since, err = time.Parse(time.RFC3339, req.Since); err != nil {
+				BadRequest(w, "since must be an RFC 3339 timestamp")
+				return
+			}
+		}
+		if req.Until != "" {
+			if f.Until, err = time.Parse(time.RFC3339, req.Until); err != nil {
+				BadRequest(w, "until must be an RFC 3339 timestamp")
+				return
+			}
+		}
+
+		entries, err := ctx.Audit.Query(f)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		httpx.OkJson(w, map[string]interface{}{
+			"entries": entries,
+			"enabled": ctx.Audit != nil,
+		})
+	}
+}
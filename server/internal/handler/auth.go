@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/auth"
+	"etcd-manager/server/internal/model"
+	"etcd-manager/server/internal/svc"
+)
+
+// minPasswordLength is the minimum length of UI user passwords
+const minPasswordLength = 8
+
+type loginReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResp struct {
+	Token     string   `json:"token"`
+	ExpiresAt int64    `json:"expiresAt"`
+	User      userItem `json:"user"`
+}
+
+type changePasswordReq struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type addUserReq struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type updateUserReq struct {
+	Username string `json:"username"`
+	Password string `json:"password,optional"`
+	Role     string `json:"role,optional"`
+}
+
+type deleteUserReq struct {
+	Username string `form:"username"`
+}
+
+// userItem is the public view of a user; the password hash is never returned
+type userItem struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+	UpdatedAt int64  `json:"updatedAt,omitempty"`
+}
+
+type meResp struct {
+	User        userItem `json:"user"`
+	AuthEnabled bool     `json:"authEnabled"`
+}
+
+func toUserItem(u model.User) userItem {
+	return userItem{
+		Username:  u.Username,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// setSessionCookie stores the token in an HttpOnly cookie so EventSource
+// requests, which cannot set headers, are authenticated too
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     auth.CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func login(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req loginReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if !ctx.Auth.Enabled() {
+			BadRequest(w, "authentication is disabled")
+			return
+		}
+
+		user, err := ctx.Users.Authenticate(req.Username, req.Password)
+		if err != nil {
+			WriteError(w, http.StatusUnauthorized, err.Error(), "")
+			return
+		}
+		token, exp, err := ctx.Tokens.Issue(user)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		setSessionCookie(w, r, token, exp)
+		httpx.OkJson(w, loginResp{Token: token, ExpiresAt: exp.Unix(), User: toUserItem(user)})
+	}
+}
+
+// logout clears the session cookie and revokes the caller's tokens, which also
+// ends their sessions elsewhere. An invalid or expired token only clears the cookie.
+func logout(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ctx.Auth.Enabled() {
+			claims, err := ctx.Tokens.Parse(auth.TokenFromRequest(r))
+			if err == nil {
+				if user, ok := ctx.Users.Get(claims.Username); ok && user.TokenVersion == claims.Version {
+					if err := ctx.Users.RevokeTokens(user.Username); err != nil {
+						InternalError(w, err)
+						return
+					}
+				}
+			}
+		}
+		setSessionCookie(w, r, "", time.Unix(0, 0))
+		httpx.Ok(w)
+	}
+}
+
+func currentUser(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ctx.Auth.Enabled() {
+			httpx.OkJson(w, meResp{User: userItem{Role: model.RoleAdmin}})
+			return
+		}
+		user, _ := auth.UserFrom(r.Context())
+		httpx.OkJson(w, meResp{User: toUserItem(user), AuthEnabled: true})
+	}
+}
+
+// changePassword changes the caller's own password. Existing sessions of the
+// user are invalidated, so a fresh token is returned.
+func changePassword(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req changePasswordReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		user, ok := auth.UserFrom(r.Context())
+		if !ok {
+			BadRequest(w, "authentication is disabled")
+			return
+		}
+		if _, err := ctx.Users.Authenticate(user.Username, req.OldPassword); err != nil {
+			BadRequest(w, "current password is incorrect")
+			return
+		}
+		if err := validatePassword(req.NewPassword); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+
+		updated, err := ctx.Users.Update(user.Username, req.NewPassword, "")
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		token, exp, err := ctx.Tokens.Issue(updated)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		setSessionCookie(w, r, token, exp)
+		httpx.OkJson(w, loginResp{Token: token, ExpiresAt: exp.Unix(), User: toUserItem(updated)})
+	}
+}
+
+func listUsers(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users := ctx.Users.List()
+		out := make([]userItem, 0, len(users))
+		for _, u := range users {
+			out = append(out, toUserItem(u))
+		}
+		httpx.OkJson(w, out)
+	}
+}
+
+func addUser(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req addUserReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := validateUsername(req.Username); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		if err := validatePassword(req.Password); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+
+		user, err := ctx.Users.Add(req.Username, req.Password, req.Role)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{Operation: opUserAdd, Target: user.Username})
+		httpx.OkJson(w, toUserItem(user))
+	}
+}
+
+func updateUser(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req updateUserReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if req.Password != "" {
+			if err := validatePassword(req.Password); err != nil {
+				BadRequest(w, err.Error())
+				return
+			}
+		}
+
+		user, err := ctx.Users.Update(req.Username, req.Password, req.Role)
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{Operation: opUserUpdate, Target: user.Username})
+		httpx.OkJson(w, toUserItem(user))
+	}
+}
+
+func deleteUser(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req deleteUserReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := ctx.Users.Remove(req.Username); err != nil {
+			writeUserError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{Operation: opUserDelete, Target: req.Username})
+		httpx.Ok(w)
+	}
+}
+
+// writeUserError maps user store errors to HTTP responses
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		NotFound(w, "user not found")
+	case errors.Is(err, model.ErrUserExists):
+		WriteError(w, http.StatusConflict, err.Error(), "")
+	case errors.Is(err, model.ErrInvalidRole), errors.Is(err, model.ErrLastAdmin):
+		BadRequest(w, err.Error())
+	default:
+		InternalError(w, err)
+	}
+}
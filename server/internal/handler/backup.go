@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"etcd-manager/server/internal/svc"
+)
+
+type snapshotReq struct {
+	ConnID string `form:"connId"`
+	// Endpoint defaults to any endpoint of the connection
+	Endpoint string `form:"endpoint,optional"`
+}
+
+type backupListReq struct {
+	ConnID string `form:"connId,optional"`
+}
+
+// snapshotFilename names a downloaded snapshot after its connection and time
+func snapshotFilename(connName string, t time.Time) string {
+	name := strings.Trim(unsafeFilenameChars.ReplaceAllString(connName, "_"), "_")
+	if name == "" {
+		name = "etcd"
+	}
+	return fmt.Sprintf("%s-%s.db", name, t.UTC().Format("20060102T150405Z"))
+}
+
+// downloadSnapshot streams a backend snapshot from one endpoint of a connection
+func downloadSnapshot(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req snapshotReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		if req.Endpoint != "" {
+			if _, err := resolveEndpoints(cli, []string{req.Endpoint}); err != nil {
+				BadRequest(w, err.Error())
+				return
+			}
+		}
+		conn, _ := ctx.Store.Get(req.ConnID)
+
+		rc, err := ctx.Manager.Snapshot(r.Context(), req.ConnID, req.Endpoint)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", snapshotFilename(conn.Name, time.Now())))
+		w.WriteHeader(http.StatusOK)
+		// The status is sent; a failure midway can only cut the download short
+		if _, err := io.Copy(w, rc); err != nil {
+			logx.Errorf("snapshot download of connection %s failed: %v", req.ConnID, err)
+		}
+	}
+}
+
+func listBackups(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req backupListReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		entries, err := ctx.Backups.List(req.ConnID)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		httpx.OkJson(w, map[string]interface{}{
+			"entries": entries,
+			"enabled": ctx.Backups != nil,
+		})
+	}
+}
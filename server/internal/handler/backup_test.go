@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotFilename(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	if got := snapshotFilename("prod / eu", at); got != "prod_eu-20260304T050607Z.db" {
+		t.Errorf("snapshotFilename() = %q", got)
+	}
+	if got := snapshotFilename("", at); got != "etcd-20260304T050607Z.db" {
+		t.Errorf("snapshotFilename() = %q", got)
+	}
+}
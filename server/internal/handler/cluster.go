@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/svc"
+)
+
+// endpointStatusTimeout bounds each per-endpoint Status call
+const endpointStatusTimeout = 3 * time.Second
+
+type clusterReq struct {
+	ConnID string `form:"connId"`
+}
+
+type addMemberReq struct {
+	ConnID   string   `json:"connId"`
+	PeerURLs []string `json:"peerURLs"`
+	Learner  bool     `json:"learner,optional"`
+	// Confirm must be true; membership changes affect quorum
+	Confirm bool `json:"confirm,optional"`
+}
+
+type memberActionReq struct {
+	ConnID  string `json:"connId"`
+	ID      string `json:"id"`
+	Confirm bool   `json:"confirm,optional"`
+}
+
+// memberItem describes a cluster member. IDs are hex strings, as printed by etcdctl.
+type memberItem struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+	IsLearner  bool     `json:"isLearner"`
+	IsLeader   bool     `json:"isLeader"`
+}
+
+type endpointStatus struct {
+	Endpoint         string   `json:"endpoint"`
+	MemberID         string   `json:"memberId,omitempty"`
+	Version          string   `json:"version,omitempty"`
+	DbSize           int64    `json:"dbSize"`
+	DbSizeInUse      int64    `json:"dbSizeInUse"`
+	Leader           string   `json:"leader,omitempty"`
+	RaftIndex        uint64   `json:"raftIndex"`
+	RaftTerm         uint64   `json:"raftTerm"`
+	RaftAppliedIndex uint64   `json:"raftAppliedIndex"`
+	IsLearner        bool     `json:"isLearner"`
+	Errors           []string `json:"errors,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+type alarmItem struct {
+	MemberID string `json:"memberId"`
+	Alarm    string `json:"alarm"`
+}
+
+type clusterResp struct {
+	ClusterID string           `json:"clusterId"`
+	Leader    string           `json:"leader,omitempty"`
+	Members   []memberItem     `json:"members"`
+	Endpoints []endpointStatus `json:"endpoints"`
+	Alarms    []alarmItem      `json:"alarms"`
+}
+
+func formatMemberID(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(id, 16)
+}
+
+func parseMemberID(s string) (uint64, error) {
+	id, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid member id %q, expected a hex string", s)
+	}
+	return id, nil
+}
+
+// endpointStatuses queries Status on every endpoint of the client. Failures are
+// reported per endpoint rather than failing the whole request.
+func endpointStatuses(ctx context.Context, cli *clientv3.Client) []endpointStatus {
+	endpoints := cli.Endpoints()
+	out := make([]endpointStatus, len(endpoints))
+	for i, ep := range endpoints {
+		out[i].Endpoint = ep
+		sctx, cancel := context.WithTimeout(ctx, endpointStatusTimeout)
+		st, err := cli.Status(sctx, ep)
+		cancel()
+		if err != nil {
+			out[i].Error = err.Error()
+			continue
+		}
+		out[i].MemberID = formatMemberID(st.Header.MemberId)
+		out[i].Version = st.Version
+		out[i].DbSize = st.DbSize
+		out[i].DbSizeInUse = st.DbSizeInUse
+		out[i].Leader = formatMemberID(st.Leader)
+		out[i].RaftIndex = st.RaftIndex
+		out[i].RaftTerm = st.RaftTerm
+		out[i].RaftAppliedIndex = st.RaftAppliedIndex
+		out[i].IsLearner = st.IsLearner
+		out[i].Errors = st.Errors
+	}
+	return out
+}
+
+func getCluster(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req clusterReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		ml, err := cli.MemberList(r.Context())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+
+		resp := clusterResp{
+			ClusterID: formatMemberID(ml.Header.ClusterId),
+			Endpoints: endpointStatuses(r.Context(), cli),
+			Alarms:    []alarmItem{},
+		}
+		for _, st := range resp.Endpoints {
+			if st.Leader != "" {
+				resp.Leader = st.Leader
+				break
+			}
+		}
+		for _, m := range ml.Members {
+			id := formatMemberID(m.ID)
+			resp.Members = append(resp.Members, memberItem{
+				ID:         id,
+				Name:       m.Name,
+				PeerURLs:   m.PeerURLs,
+				ClientURLs: m.ClientURLs,
+				IsLearner:  m.IsLearner,
+				IsLeader:   id == resp.Leader,
+			})
+		}
+
+		alarms, err := cli.AlarmList(r.Context())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		for _, a := range alarms.Alarms {
+			resp.Alarms = append(resp.Alarms, alarmItem{MemberID: formatMemberID(a.MemberID), Alarm: a.Alarm.String()})
+		}
+
+		httpx.OkJson(w, resp)
+	}
+}
+
+func addMember(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req addMemberReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if !req.Confirm {
+			BadRequest(w, "member changes require confirm=true")
+			return
+		}
+		if len(req.PeerURLs) == 0 {
+			BadRequest(w, "at least one peer URL is required")
+			return
+		}
+		for _, u := range req.PeerURLs {
+			if err := validateEndpoint(u); err != nil {
+				BadRequest(w, "invalid peer URL: "+err.Error())
+				return
+			}
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		var resp *clientv3.MemberAddResponse
+		var err error
+		if req.Learner {
+			resp, err = cli.MemberAddAsLearner(r.Context(), req.PeerURLs)
+		} else {
+			resp, err = cli.MemberAdd(r.Context(), req.PeerURLs)
+		}
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		m := resp.Member
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opMemberAdd,
+			Target:    formatMemberID(m.ID),
+		})
+		httpx.OkJson(w, memberItem{
+			ID:         formatMemberID(m.ID),
+			Name:       m.Name,
+			PeerURLs:   m.PeerURLs,
+			ClientURLs: m.ClientURLs,
+			IsLearner:  m.IsLearner,
+		})
+	}
+}
+
+func removeMember(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req memberActionReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if !req.Confirm {
+			BadRequest(w, "member changes require confirm=true")
+			return
+		}
+		id, err := parseMemberID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		if _, err := cli.MemberRemove(r.Context(), id); err != nil {
+			InternalError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{ConnID: req.ConnID, Operation: opMemberRemove, Target: req.ID})
+		httpx.Ok(w)
+	}
+}
+
+func promoteMember(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req memberActionReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if !req.Confirm {
+			BadRequest(w, "member changes require confirm=true")
+			return
+		}
+		id, err := parseMemberID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		if _, err := cli.MemberPromote(r.Context(), id); err != nil {
+			InternalError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{ConnID: req.ConnID, Operation: opMemberPromote, Target: req.ID})
+		httpx.Ok(w)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"etcd-manager/server/internal/svc"
+)
+
+func TestMemberIDRoundTrip(t *testing.T) {
+	// Member ids use the full uint64 range
+	const id uint64 = 0xfedcba9876543210
+
+	s := formatMemberID(id)
+	if s != "fedcba9876543210" {
+		t.Errorf("formatMemberID() = %q", s)
+	}
+	got, err := parseMemberID(s)
+	if err != nil {
+		t.Fatalf("parseMemberID(%q) unexpected error = %v", s, err)
+	}
+	if got != id {
+		t.Errorf("parseMemberID(%q) = %x, want %x", s, got, id)
+	}
+	if formatMemberID(0) != "" {
+		t.Errorf("formatMemberID(0) = %q, want empty", formatMemberID(0))
+	}
+}
+
+func TestParseMemberIDInvalid(t *testing.T) {
+	for _, s := range []string{"", "0", "-1", "xyz", "10000000000000000"} {
+		if _, err := parseMemberID(s); err == nil {
+			t.Errorf("parseMemberID(%q) expected error but got nil", s)
+		}
+	}
+}
+
+// TestMemberChangeValidation covers requests rejected before any connection
+// lookup, so the handlers run without a manager
+func TestMemberChangeValidation(t *testing.T) {
+	ctx := &svc.ServiceContext{}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		wantMsg string
+	}{
+		{"add without confirm", addMember(ctx), `{"connId": "c", "peerURLs": ["http://10.0.0.4:2380"]}`, "require confirm=true"},
+		{"add with confirm false", addMember(ctx), `{"connId": "c", "peerURLs": ["http://10.0.0.4:2380"], "confirm": false}`, "require confirm=true"},
+		{"add without peer URLs", addMember(ctx), `{"connId": "c", "peerURLs": [], "confirm": true}`, "at least one peer URL"},
+		{"add invalid peer URL", addMember(ctx), `{"connId": "c", "peerURLs": ["ftp://x"], "confirm": true}`, "invalid peer URL"},
+		{"remove without confirm", removeMember(ctx), `{"connId": "c", "id": "8e9e05c52164694d"}`, "require confirm=true"},
+		{"remove invalid id", removeMember(ctx), `{"connId": "c", "id": "zz", "confirm": true}`, "invalid member id"},
+		{"promote without confirm", promoteMember(ctx), `{"connId": "c", "id": "8e9e05c52164694d"}`, "require confirm=true"},
+		{"promote invalid id", promoteMember(ctx), `{"connId": "c", "id": "0", "confirm": true}`, "invalid member id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/cluster/members", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusBadRequest, w.Body.String())
+			}
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid error body: %v", err)
+			}
+			if !strings.Contains(resp.Message, tt.wantMsg) {
+				t.Errorf("message = %q, want containing %q", resp.Message, tt.wantMsg)
+			}
+		})
+	}
+}
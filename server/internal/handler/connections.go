@@ -2,17 +2,23 @@ package handler
 
 import (
     "net/http"
+    "time"
 
     "github.com/zeromicro/go-zero/rest/httpx"
 
+    "etcd-manager/server/internal/audit"
+    "etcd-manager/server/internal/etcd"
+    "etcd-manager/server/internal/model"
     "etcd-manager/server/internal/svc"
 )
 
 type addConnReq struct {
-    Name      string   `json:"name"`
-    Endpoints []string `json:"endpoints"`
-    Username  string   `json:"username,optional"`
-    Password  string   `json:"password,optional"`
+    Name       string         `json:"name"`
+    Endpoints  []string       `json:"endpoints"`
+    Username   string         `json:"username,optional"`
+    Password   string         `json:"password,optional"`
+    TLS        *tlsReq        `json:"tls,optional"`
+    Protection *protectionReq `json:"protection,optional"`
 }
 
 type updateConnReq struct {
@@ -21,11 +27,148 @@ type updateConnReq struct {
     Endpoints []string `json:"endpoints,optional"`
     Username  string   `json:"username,optional"`
     Password  string   `json:"password,optional"`
+    // ClearPassword removes the stored password; an empty Password means unchanged
+    ClearPassword bool `json:"clearPassword,optional"`
+    // nil keeps the current TLS settings, an empty object disables TLS
+    TLS *tlsReq `json:"tls,optional"`
+    // nil keeps the current protection settings
+    Protection *protectionReq `json:"protection,optional"`
+}
+
+// protectionReq guards a connection against accidental writes
+type protectionReq struct {
+    ReadOnly          bool     `json:"readOnly,optional"`
+    ProtectedPrefixes []string `json:"protectedPrefixes,optional"`
+}
+
+// connResp is the API view of a connection. Secrets are never returned,
+// only whether they are set.
+type connResp struct {
+    ID                string   `json:"id"`
+    Name              string   `json:"name"`
+    Endpoints         []string `json:"endpoints"`
+    Username          string   `json:"username,omitempty"`
+    HasPassword       bool     `json:"hasPassword"`
+    TLS               *tlsResp `json:"tls,omitempty"`
+    ReadOnly          bool     `json:"readOnly"`
+    ProtectedPrefixes []string `json:"protectedPrefixes"`
+    Status            string   `json:"status"`
+    UpdatedAt         int64    `json:"updatedAt"`
+    // Set while the connection is monitored, from its latest probes
+    LastError     string                `json:"lastError,omitempty"`
+    LastProbeAt   *time.Time            `json:"lastProbeAt,omitempty"`
+    LastHealthyAt *time.Time            `json:"lastHealthyAt,omitempty"`
+    LatencyMs     int64                 `json:"latencyMs,omitempty"`
+    Failures      int                   `json:"failures,omitempty"`
+    NextProbeAt   *time.Time            `json:"nextProbeAt,omitempty"`
+    Health        []etcd.EndpointHealth `json:"health,omitempty"`
+}
+
+// connView adds the monitored health of a connection to its API view
+func connView(ctx *svc.ServiceContext, c model.Connection) connResp {
+    resp := toConnResp(c)
+    h, ok := ctx.Manager.Health(c.ID)
+    if !ok {
+        return resp
+    }
+    resp.LastError = h.LastError
+    resp.LastProbeAt = &h.LastProbe
+    if !h.LastHealthy.IsZero() {
+        resp.LastHealthyAt = &h.LastHealthy
+    }
+    resp.LatencyMs = h.Latency.Milliseconds()
+    resp.Failures = h.Failures
+    if h.Failures > 0 {
+        resp.NextProbeAt = &h.NextProbe
+    }
+    resp.Health = h.Endpoints
+    return resp
+}
+
+// tlsResp returns certificates and file paths but masks the inline client key
+type tlsResp struct {
+    CACert             string `json:"caCert,omitempty"`
+    CACertFile         string `json:"caCertFile,omitempty"`
+    ClientCert         string `json:"clientCert,omitempty"`
+    ClientCertFile     string `json:"clientCertFile,omitempty"`
+    HasClientKey       bool   `json:"hasClientKey"`
+    ClientKeyFile      string `json:"clientKeyFile,omitempty"`
+    ServerName         string `json:"serverName,omitempty"`
+    InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+func toConnResp(c model.Connection) connResp {
+    resp := connResp{
+        ID:                c.ID,
+        Name:              c.Name,
+        Endpoints:         c.Endpoints,
+        Username:          c.Username,
+        HasPassword:       c.Password != "",
+        ReadOnly:          c.ReadOnly,
+        ProtectedPrefixes: c.ProtectedPrefixes,
+        Status:            c.Status,
+        UpdatedAt:         c.UpdatedAt,
+    }
+    if c.TLS != nil {
+        resp.TLS = &tlsResp{
+            CACert:             c.TLS.CACert,
+            CACertFile:         c.TLS.CACertFile,
+            ClientCert:         c.TLS.ClientCert,
+            ClientCertFile:     c.TLS.ClientCertFile,
+            HasClientKey:       c.TLS.ClientKey != "",
+            ClientKeyFile:      c.TLS.ClientKeyFile,
+            ServerName:         c.TLS.ServerName,
+            InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+        }
+    }
+    if resp.ProtectedPrefixes == nil {
+        resp.ProtectedPrefixes = []string{}
+    }
+    return resp
+}
+
+// tlsReq carries TLS material either inline (PEM content) or as file paths relative
+// to the server's certificate directory.
+type tlsReq struct {
+    CACert             string `json:"caCert,optional"`
+    CACertFile         string `json:"caCertFile,optional"`
+    ClientCert         string `json:"clientCert,optional"`
+    ClientCertFile     string `json:"clientCertFile,optional"`
+    ClientKey          string `json:"clientKey,optional"`
+    ClientKeyFile      string `json:"clientKeyFile,optional"`
+    ServerName         string `json:"serverName,optional"`
+    InsecureSkipVerify bool   `json:"insecureSkipVerify,optional"`
+}
+
+// toModel converts the request into a stored TLS config, returning nil when nothing is set.
+func (t *tlsReq) toModel() *model.TLSConfig {
+    if t == nil {
+        return nil
+    }
+    cfg := model.TLSConfig{
+        CACert:             t.CACert,
+        CACertFile:         t.CACertFile,
+        ClientCert:         t.ClientCert,
+        ClientCertFile:     t.ClientCertFile,
+        ClientKey:          t.ClientKey,
+        ClientKeyFile:      t.ClientKeyFile,
+        ServerName:         t.ServerName,
+        InsecureSkipVerify: t.InsecureSkipVerify,
+    }
+    if cfg == (model.TLSConfig{}) {
+        return nil
+    }
+    return &cfg
 }
 
 func listConnections(ctx *svc.ServiceContext) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
-        httpx.OkJson(w, ctx.Store.List())
+        list := ctx.Store.List()
+        out := make([]connResp, 0, len(list))
+        for _, c := range list {
+            out = append(out, connView(ctx, c))
+        }
+        httpx.OkJson(w, out)
     }
 }
 
@@ -55,12 +198,36 @@ func addConnection(ctx *svc.ServiceContext) http.HandlerFunc {
             }
         }
 
-        conn, err := ctx.Store.Add(req.Name, req.Endpoints, req.Username, req.Password)
+        tlsCfg := req.TLS.toModel()
+        if err := validateTLS(tlsCfg); err != nil {
+            BadRequest(w, err.Error())
+            return
+        }
+        if req.Protection != nil {
+            if err := validateProtectedPrefixes(req.Protection.ProtectedPrefixes); err != nil {
+                BadRequest(w, err.Error())
+                return
+            }
+        }
+
+        conn := model.Connection{
+            Name:      req.Name,
+            Endpoints: req.Endpoints,
+            Username:  req.Username,
+            Password:  req.Password,
+            TLS:       tlsCfg,
+        }
+        if req.Protection != nil {
+            conn.ReadOnly = req.Protection.ReadOnly
+            conn.ProtectedPrefixes = req.Protection.ProtectedPrefixes
+        }
+        conn, err := ctx.Store.Create(conn)
         if err != nil {
             InternalError(w, err)
             return
         }
-        httpx.OkJson(w, conn)
+        recordAudit(ctx, r, audit.Entry{ConnID: conn.ID, Operation: opConnectionAdd, Target: conn.Name})
+        httpx.OkJson(w, connView(ctx, conn))
     }
 }
 
@@ -90,12 +257,66 @@ func updateConnection(ctx *svc.ServiceContext) http.HandlerFunc {
             }
         }
 
-        conn, err := ctx.Store.Update(req.ID, req.Name, req.Endpoints, req.Username, req.Password)
+        if req.ClearPassword && req.Password != "" {
+            BadRequest(w, "password and clearPassword are mutually exclusive")
+            return
+        }
+
+        existing, ok := ctx.Store.Get(req.ID)
+        if !ok {
+            NotFound(w, "connection not found")
+            return
+        }
+
+        tlsCfg := req.TLS.toModel()
+        // The inline client key is never sent to the browser, so keep the
+        // stored one when the client certificate is resubmitted without a key.
+        if tlsCfg != nil && tlsCfg.ClientKey == "" && tlsCfg.ClientKeyFile == "" &&
+            (tlsCfg.ClientCert != "" || tlsCfg.ClientCertFile != "") &&
+            existing.TLS != nil && existing.TLS.ClientKey != "" {
+            tlsCfg.ClientKey = existing.TLS.ClientKey
+        }
+        if err := validateTLS(tlsCfg); err != nil {
+            BadRequest(w, err.Error())
+            return
+        }
+        if req.Protection != nil {
+            if err := validateProtectedPrefixes(req.Protection.ProtectedPrefixes); err != nil {
+                BadRequest(w, err.Error())
+                return
+            }
+        }
+
+        conn, err := ctx.Store.Modify(req.ID, func(v *model.Connection) {
+            if req.Name != "" {
+                v.Name = req.Name
+            }
+            if len(req.Endpoints) > 0 {
+                v.Endpoints = req.Endpoints
+            }
+            if req.Username != "" {
+                v.Username = req.Username
+            }
+            if req.Password != "" {
+                v.Password = req.Password
+            }
+            if req.ClearPassword {
+                v.Password = ""
+            }
+            if req.TLS != nil {
+                v.TLS = tlsCfg
+            }
+            if req.Protection != nil {
+                v.ReadOnly = req.Protection.ReadOnly
+                v.ProtectedPrefixes = req.Protection.ProtectedPrefixes
+            }
+        })
         if err != nil {
             InternalError(w, err)
             return
         }
-        httpx.OkJson(w, conn)
+        recordAudit(ctx, r, audit.Entry{ConnID: conn.ID, Operation: opConnectionUpdate, Target: conn.Name})
+        httpx.OkJson(w, connView(ctx, conn))
     }
 }
 
@@ -106,11 +327,13 @@ func deleteConnection(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.WriteJson(w, http.StatusBadRequest, map[string]string{"message": "missing id"})
             return
         }
+        conn, _ := ctx.Store.Get(id)
         _ = ctx.Manager.Disconnect(id)
         if err := ctx.Store.Remove(id); err != nil {
             httpx.Error(w, err)
             return
         }
+        recordAudit(ctx, r, audit.Entry{ConnID: id, Operation: opConnectionDelete, Target: conn.Name})
         httpx.Ok(w)
     }
 }
@@ -128,7 +351,7 @@ func connect(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
         conn, _ := ctx.Store.Get(id)
-        httpx.OkJson(w, conn)
+        httpx.OkJson(w, connView(ctx, conn))
     }
 }
 
@@ -144,6 +367,6 @@ func disconnect(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
         conn, _ := ctx.Store.Get(id)
-        httpx.OkJson(w, conn)
+        httpx.OkJson(w, connView(ctx, conn))
     }
 }
\ No newline at end of file
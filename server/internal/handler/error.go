@@ -10,7 +10,8 @@ import (
 type ErrorResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	// Details is a string, or structured data such as schema violations
+	Details interface{} `json:"details,omitempty"`
 }
 
 // WriteError writes a structured error response with custom code, message, and details
@@ -18,7 +19,9 @@ func WriteError(w http.ResponseWriter, code int, message string, details string)
 	resp := ErrorResponse{
 		Code:    code,
 		Message: message,
-		Details: details,
+	}
+	if details != "" {
+		resp.Details = details
 	}
 	httpx.WriteJson(w, code, resp)
 }
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"etcd-manager/server/internal/model"
+	"etcd-manager/server/internal/svc"
+)
+
+// writeTarget is a key, or a whole subtree, that a request is about to modify
+type writeTarget struct {
+	Key     string
+	Subtree bool
+	// Prefix covers every key that starts with Key, as etcd's WithPrefix does
+	Prefix bool
+}
+
+func keyTarget(key string) writeTarget {
+	return writeTarget{Key: key}
+}
+
+// subtreeTarget covers key itself and every key below key + "/"
+func subtreeTarget(key string) writeTarget {
+	return writeTarget{Key: key, Subtree: true}
+}
+
+// prefixTarget covers every key that starts with prefix
+func prefixTarget(prefix string) writeTarget {
+	return writeTarget{Key: prefix, Prefix: true}
+}
+
+// writeBlockedError describes the connection rule that rejected a write
+type writeBlockedError struct {
+	Rule    string // "readOnly" or "protectedPrefix"
+	Prefix  string
+	Message string
+}
+
+func (e *writeBlockedError) Error() string {
+	return e.Message
+}
+
+// protectedBy returns the first protected prefix that covers any key of t
+func protectedBy(prefixes []string, t writeTarget) (string, bool) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(t.Key, p) {
+			return p, true
+		}
+		if t.Prefix && strings.HasPrefix(p, t.Key) {
+			return p, true
+		}
+		if t.Subtree {
+			base := subtreeBase(t.Key) + "/"
+			// Keys below the subtree are protected, or the prefix lies inside it
+			if strings.HasPrefix(base, p) || strings.HasPrefix(p, base) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkWritable returns a *writeBlockedError when conn forbids writing to any of targets
+func checkWritable(conn model.Connection, targets ...writeTarget) error {
+	if conn.ReadOnly {
+		return &writeBlockedError{
+			Rule:    "readOnly",
+			Message: fmt.Sprintf("connection %q is read-only", conn.Name),
+		}
+	}
+	for _, t := range targets {
+		if p, ok := protectedBy(conn.ProtectedPrefixes, t); ok {
+			msg := fmt.Sprintf("key %q is under protected prefix %q of connection %q", t.Key, p, conn.Name)
+			if t.Subtree {
+				msg = fmt.Sprintf("subtree %q overlaps protected prefix %q of connection %q", t.Key, p, conn.Name)
+			} else if t.Prefix {
+				msg = fmt.Sprintf("prefix %q overlaps protected prefix %q of connection %q", t.Key, p, conn.Name)
+			}
+			return &writeBlockedError{
+				Rule:    "protectedPrefix",
+				Prefix:  p,
+				Message: msg,
+			}
+		}
+	}
+	return nil
+}
+
+// guardWrite enforces the read-only flag and protected prefixes of a connection.
+// It writes a 403 naming the blocking rule and returns false when the write must not proceed.
+// Unknown connections pass; the caller's connection lookup reports them.
+func guardWrite(w http.ResponseWriter, ctx *svc.ServiceContext, connID string, targets ...writeTarget) bool {
+	conn, ok := ctx.Store.Get(connID)
+	if !ok {
+		return true
+	}
+	if err := checkWritable(conn, targets...); err != nil {
+		blocked := err.(*writeBlockedError)
+		details := blocked.Rule
+		if blocked.Prefix != "" {
+			details += ": " + blocked.Prefix
+		}
+		WriteError(w, http.StatusForbidden, blocked.Message, details)
+		return false
+	}
+	return true
+}
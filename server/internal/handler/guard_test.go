@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"etcd-manager/server/internal/model"
+)
+
+func TestCheckWritable(t *testing.T) {
+	conn := model.Connection{Name: "prod", ProtectedPrefixes: []string{"/config/", "/locks"}}
+
+	tests := []struct {
+		name       string
+		conn       model.Connection
+		target     writeTarget
+		wantRule   string
+		wantPrefix string
+	}{
+		{"unprotected key", conn, keyTarget("/app/a"), "", ""},
+		{"key under prefix", conn, keyTarget("/config/db"), "protectedPrefix", "/config/"},
+		{"prefix without slash", conn, keyTarget("/locks-2"), "protectedPrefix", "/locks"},
+		{"directory key itself is not under /config/", conn, keyTarget("/config"), "", ""},
+		{"subtree containing prefix", conn, subtreeTarget("/"), "protectedPrefix", "/config/"},
+		{"subtree whose children are protected", conn, subtreeTarget("/config"), "protectedPrefix", "/config/"},
+		{"subtree inside prefix", conn, subtreeTarget("/config/app/"), "protectedPrefix", "/config/"},
+		{"sibling subtree", conn, subtreeTarget("/configs"), "", ""},
+		{"prefix containing protected prefix", conn, prefixTarget("/conf"), "protectedPrefix", "/config/"},
+		{"prefix inside protected prefix", conn, prefixTarget("/config/app"), "protectedPrefix", "/config/"},
+		{"unrelated prefix", conn, prefixTarget("/app/"), "", ""},
+		{"read-only", model.Connection{Name: "prod", ReadOnly: true}, keyTarget("/app/a"), "readOnly", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWritable(tt.conn, tt.target)
+			if tt.wantRule == "" {
+				if err != nil {
+					t.Errorf("checkWritable() unexpected error = %v", err)
+				}
+				return
+			}
+			var blocked *writeBlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("checkWritable() error = %v, want *writeBlockedError", err)
+			}
+			if blocked.Rule != tt.wantRule || blocked.Prefix != tt.wantPrefix {
+				t.Errorf("checkWritable() rule = %q prefix = %q, want %q %q", blocked.Rule, blocked.Prefix, tt.wantRule, tt.wantPrefix)
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package handler
 
 import (
+    "errors"
     "fmt"
     "net/http"
     "strings"
+    "time"
 
     "github.com/zeromicro/go-zero/rest/httpx"
+    "go.etcd.io/etcd/api/v3/mvccpb"
     clientv3 "go.etcd.io/etcd/client/v3"
 
+    "etcd-manager/server/internal/audit"
     "etcd-manager/server/internal/svc"
 )
 
@@ -15,6 +19,11 @@ type listReq struct {
     ConnID string `form:"connId"`
     Prefix string `form:"prefix"`
     IncludeTTL bool `form:"includeTTL"`
+    // Limit > 0 enables paged, keys-only listing
+    Limit  int    `form:"limit,optional"`
+    Cursor string `form:"cursor,optional"`
+    // Rev > 0 lists the tree as of that revision; TTLs are only reported for the current one
+    Rev int64 `form:"rev,optional"`
 }
 
 type keyItem struct {
@@ -22,16 +31,28 @@ type keyItem struct {
     Value string `json:"value,omitempty"`
     IsDir bool   `json:"isDir"`
     TTL   int64  `json:"ttl"`
+    Lease string `json:"lease,omitempty"` // hex lease id
+    // Format is the detected structure of the value, empty for plain text
+    Format string `json:"format,omitempty"`
+    // Encoding is utf8, or base64 for values that are not valid UTF-8
+    Encoding string `json:"encoding,omitempty"`
+    // ModRevision and Version are set when fetching a single key
+    ModRevision int64 `json:"modRevision,omitempty"`
+    Version     int64 `json:"version,omitempty"`
 }
 
 type listResp struct {
     Prefix   string    `json:"prefix"`
     Children []keyItem `json:"children"`
+    HasMore  bool      `json:"hasMore,omitempty"`
+    Cursor   string    `json:"cursor,omitempty"`
+    Revision int64     `json:"revision,omitempty"` // set when listing a past revision
 }
 
 type getReq struct {
     ConnID string `form:"connId"`
     Key    string `form:"key"`
+    Rev    int64  `form:"rev,optional"`
 }
 
 type putReq struct {
@@ -39,6 +60,28 @@ type putReq struct {
     Key    string `json:"key"`
     Value  string `json:"value"`
     TTL    int64  `json:"ttl"`
+    // Lease attaches the key to an existing lease (hex id) instead of granting one for TTL
+    Lease  string `json:"lease,optional"`
+    // Format rejects values that do not parse as json, yaml, toml or properties
+    Format string `json:"format,optional"`
+    // Encoding of Value: utf8 (default) or base64 for binary values
+    Encoding string `json:"encoding,optional"`
+    // ExpectedModRevision > 0 makes the put fail with 409 if the key changed since
+    ExpectedModRevision int64 `json:"expectedModRevision,optional"`
+}
+
+// editConflictResp is the 409 of a put whose expected ModRevision is stale.
+// It carries the current value so the editor can merge.
+type editConflictResp struct {
+    ErrorResponse
+    Key                 string `json:"key"`
+    ExpectedModRevision int64  `json:"expectedModRevision"`
+    // Exists is false when the key was deleted in the meantime
+    Exists      bool   `json:"exists"`
+    Value       string `json:"value,omitempty"`
+    Encoding    string `json:"encoding,omitempty"`
+    ModRevision int64  `json:"modRevision,omitempty"`
+    Version     int64  `json:"version,omitempty"`
 }
 
 type renameReq struct {
@@ -46,6 +89,8 @@ type renameReq struct {
     From   string `json:"from"`
     To     string `json:"to"`
     Overwrite bool   `json:"overwrite"`
+    // Recursive moves the key and every key under From/ to To/
+    Recursive bool   `json:"recursive,optional"`
 }
 
 type batchDeleteReq struct {
@@ -58,14 +103,26 @@ type copyReq struct {
     From   string `json:"from"`
     To     string `json:"to"`
     Overwrite bool `json:"overwrite"`
+    // Recursive copies the key and every key under From/ to To/
+    Recursive     bool   `json:"recursive,optional"`
+    // TargetConnID writes to another connection (recursive mode only)
+    TargetConnID  string `json:"targetConnId,optional"`
+    // Conflict is overwrite, skip or fail; defaults from Overwrite
+    Conflict      string `json:"conflict,optional"`
+    PreserveLease bool   `json:"preserveLease,optional"`
+    DryRun        bool   `json:"dryRun,optional"`
 }
 
 type keyRevision struct {
     Revision    int64  `json:"revision"`
     Value       string `json:"value"`
+    Encoding    string `json:"encoding"`
     ModRevision int64  `json:"modRevision"`
-    CreateTime  int64  `json:"createTime"`
+    CreateTime  int64  `json:"createTime"` // create revision of the key
     Version     int64  `json:"version"`
+    // Time and User come from the audit log, for changes made through the manager
+    Time *time.Time `json:"time,omitempty"`
+    User string     `json:"user,omitempty"`
 }
 
 type getHistoryReq struct {
@@ -74,6 +131,16 @@ type getHistoryReq struct {
     Limit  int    `form:"limit,default=10"`
 }
 
+type historyResp struct {
+    Key     string        `json:"key"`
+    History []keyRevision `json:"history"` // newest first
+    // CompactRevision is set when the key's older versions were compacted away
+    CompactRevision int64 `json:"compactRevision,omitempty"`
+    // Complete is false when fewer versions than requested remain because of compaction
+    Complete bool   `json:"complete"`
+    Note     string `json:"note,omitempty"`
+}
+
 type rollbackReq struct {
     ConnID   string `json:"connId"`
     Key      string `json:"key"`
@@ -99,8 +166,45 @@ func listKeys(ctx *svc.ServiceContext) http.HandlerFunc {
         if !strings.HasSuffix(prefix, "/") {
             prefix += "/"
         }
-        resp, err := cli.Get(r.Context(), prefix, clientv3.WithPrefix())
+        var revOpts []clientv3.OpOption
+        if req.Rev > 0 {
+            revOpts = append(revOpts, clientv3.WithRev(req.Rev))
+            req.IncludeTTL = false
+        }
+        if req.Limit > 0 {
+            if req.Limit > maxListLimit {
+                req.Limit = maxListLimit
+            }
+            page, cursor, err := listChildrenPaged(r.Context(), cli, prefix, req.Limit, req.Cursor, req.Rev)
+            if err != nil {
+                if errors.Is(err, errInvalidCursor) {
+                    BadRequest(w, err.Error())
+                    return
+                }
+                if writeRevisionError(w, err, req.Rev) {
+                    return
+                }
+                httpx.Error(w, err)
+                return
+            }
+            out := make([]keyItem, 0, len(page))
+            for _, c := range page {
+                if req.IncludeTTL && !c.item.IsDir && c.lease > 0 {
+                    lt, err := cli.TimeToLive(r.Context(), clientv3.LeaseID(c.lease))
+                    if err == nil && lt.TTL > 0 {
+                        c.item.TTL = lt.TTL
+                    }
+                }
+                out = append(out, c.item)
+            }
+            httpx.OkJson(w, listResp{Prefix: prefix, Children: out, HasMore: cursor != "", Cursor: cursor, Revision: req.Rev})
+            return
+        }
+        resp, err := cli.Get(r.Context(), prefix, append(revOpts, clientv3.WithPrefix())...)
         if err != nil {
+            if writeRevisionError(w, err, req.Rev) {
+                return
+            }
             httpx.Error(w, err)
             return
         }
@@ -108,6 +212,8 @@ func listKeys(ctx *svc.ServiceContext) http.HandlerFunc {
         type child struct{
             isDir bool
             value string
+            encoding string
+            format string
             lease int64
         }
         children := map[string]child{}
@@ -123,11 +229,14 @@ func listKeys(ctx *svc.ServiceContext) http.HandlerFunc {
             }
             // If the remain contains '/', then seg is a dir
             isDir := strings.Contains(remain, "/")
-            v := ""
+            var v, enc, format string
             if !isDir {
-                v = string(kv.Value)
+                v, enc = encodeValue(kv.Value)
+                if enc == encodingUTF8 {
+                    format = detectFormat(v)
+                }
             }
-            children[seg] = child{isDir: isDir, value: v, lease: int64(kv.Lease)}
+            children[seg] = child{isDir: isDir, value: v, encoding: enc, format: format, lease: int64(kv.Lease)}
         }
         out := make([]keyItem, 0, len(children))
         for name, c := range children {
@@ -139,14 +248,16 @@ func listKeys(ctx *svc.ServiceContext) http.HandlerFunc {
                 }
             }
             ki := keyItem{
-                Key:   prefix + name,
-                Value: c.value,
-                IsDir: c.isDir,
-                TTL:   ttl,
+                Key:      prefix + name,
+                Value:    c.value,
+                IsDir:    c.isDir,
+                TTL:      ttl,
+                Format:   c.format,
+                Encoding: c.encoding,
             }
             out = append(out, ki)
         }
-        httpx.OkJson(w, listResp{Prefix: prefix, Children: out})
+        httpx.OkJson(w, listResp{Prefix: prefix, Children: out, Revision: req.Rev})
     }
 }
 
@@ -162,8 +273,15 @@ func getKey(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.WriteJson(w, http.StatusBadRequest, map[string]string{"message": "invalid connId or not connected"})
             return
         }
-        resp, err := cli.Get(r.Context(), req.Key)
+        var opts []clientv3.OpOption
+        if req.Rev > 0 {
+            opts = append(opts, clientv3.WithRev(req.Rev))
+        }
+        resp, err := cli.Get(r.Context(), req.Key, opts...)
         if err != nil {
+            if writeRevisionError(w, err, req.Rev) {
+                return
+            }
             httpx.Error(w, err)
             return
         }
@@ -171,15 +289,30 @@ func getKey(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.WriteJson(w, http.StatusNotFound, map[string]string{"message": "key not found"})
             return
         }
-        // Try to fetch TTL from lease if present
+        // Try to fetch TTL from lease if present; leases only exist now
         ttl := int64(0)
-        if resp.Kvs[0].Lease > 0 {
+        if resp.Kvs[0].Lease > 0 && req.Rev == 0 {
             lt, err := cli.TimeToLive(r.Context(), clientv3.LeaseID(resp.Kvs[0].Lease))
             if err == nil && lt.TTL > 0 {
                 ttl = lt.TTL
             }
         }
-        httpx.OkJson(w, keyItem{Key: req.Key, Value: string(resp.Kvs[0].Value), IsDir: false, TTL: ttl})
+        value, encoding := encodeValue(resp.Kvs[0].Value)
+        kv := resp.Kvs[0]
+        item := keyItem{
+            Key:         req.Key,
+            Value:       value,
+            IsDir:       false,
+            TTL:         ttl,
+            Lease:       formatLeaseID(kv.Lease),
+            Encoding:    encoding,
+            ModRevision: kv.ModRevision,
+            Version:     kv.Version,
+        }
+        if encoding == encodingUTF8 {
+            item.Format = detectFormat(value)
+        }
+        httpx.OkJson(w, item)
     }
 }
 
@@ -197,36 +330,116 @@ func putKey(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
+        // From here on Value holds the raw bytes
+        value, err := decodeValue(req.Value, req.Encoding)
+        if err != nil {
+            BadRequest(w, err.Error())
+            return
+        }
+        req.Value = value
+
         // Validate value size
         if err := validateValue(req.Value); err != nil {
             BadRequest(w, err.Error())
             return
         }
+        if !writeValueCheck(w, req.Format, req.Value) {
+            return
+        }
+        if !writeSchemaCheck(w, ctx, req.ConnID, pendingValue{req.Key, []byte(req.Value)}) {
+            return
+        }
+        if !guardWrite(w, ctx, req.ConnID, keyTarget(req.Key)) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
             BadRequest(w, "invalid connId or not connected")
             return
         }
-        // handle TTL by lease
+        // handle TTL by lease, or attach to an existing lease
         var opts []clientv3.OpOption
-        if req.TTL > 0 {
+        var granted clientv3.LeaseID
+        if req.Lease != "" {
+            if req.TTL > 0 {
+                BadRequest(w, "ttl and lease are mutually exclusive")
+                return
+            }
+            id, err := parseLeaseID(req.Lease)
+            if err != nil {
+                BadRequest(w, err.Error())
+                return
+            }
+            opts = append(opts, clientv3.WithLease(id))
+        } else if req.TTL > 0 {
             lr, err := cli.Grant(r.Context(), req.TTL)
             if err != nil {
                 InternalError(w, err)
                 return
             }
             opts = append(opts, clientv3.WithLease(lr.ID))
-        }
-        _, err := cli.Put(r.Context(), req.Key, req.Value, opts...)
+            // Revoked unless the put below succeeds
+            granted = lr.ID
+            defer func() { revokeUnusedLease(cli, granted) }()
+        }
+        opts = append(opts, clientv3.WithPrevKV())
+        // An expected ModRevision turns the put into a compare-and-swap
+        var cmps []clientv3.Cmp
+        if req.ExpectedModRevision > 0 {
+            cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(req.Key), "=", req.ExpectedModRevision))
+        }
+        tResp, err := cli.Txn(r.Context()).If(cmps...).
+            Then(clientv3.OpPut(req.Key, req.Value, opts...)).
+            Else(clientv3.OpGet(req.Key)).
+            Commit()
         if err != nil {
+            if isLeaseNotFound(err) {
+                BadRequest(w, "lease not found")
+                return
+            }
             InternalError(w, err)
             return
         }
-        httpx.Ok(w)
+        if !tResp.Succeeded {
+            writeEditConflict(w, req.Key, req.ExpectedModRevision, tResp.Responses[0].GetResponseRange().Kvs)
+            return
+        }
+        granted = 0
+        entry := audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opPut,
+            Keys:      []string{req.Key},
+            NewHash:   audit.HashValue([]byte(req.Value)),
+            Revision:  tResp.Header.Revision,
+        }
+        if prev := tResp.Responses[0].GetResponsePut().PrevKv; prev != nil {
+            entry.OldHash = audit.HashValue(prev.Value)
+        }
+        recordAudit(ctx, r, entry)
+        httpx.OkJson(w, map[string]int64{"modRevision": tResp.Header.Revision})
     }
 }
 
+// writeEditConflict writes the 409 of a stale put with the current state of key
+func writeEditConflict(w http.ResponseWriter, key string, expected int64, kvs []*mvccpb.KeyValue) {
+    resp := editConflictResp{
+        ErrorResponse:       ErrorResponse{Code: http.StatusConflict},
+        Key:                 key,
+        ExpectedModRevision: expected,
+    }
+    if len(kvs) == 0 {
+        resp.Message = fmt.Sprintf("key was deleted after revision %d", expected)
+    } else {
+        kv := kvs[0]
+        resp.Exists = true
+        resp.Value, resp.Encoding = encodeValue(kv.Value)
+        resp.ModRevision, resp.Version = kv.ModRevision, kv.Version
+        resp.Message = fmt.Sprintf("key was modified at revision %d, expected %d", kv.ModRevision, expected)
+    }
+    httpx.WriteJson(w, http.StatusConflict, resp)
+}
+
 func createKey(ctx *svc.ServiceContext) http.HandlerFunc {
     return func(w http.ResponseWriter, r *http.Request) {
         var req putReq
@@ -241,11 +454,28 @@ func createKey(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
+        // From here on Value holds the raw bytes
+        value, err := decodeValue(req.Value, req.Encoding)
+        if err != nil {
+            BadRequest(w, err.Error())
+            return
+        }
+        req.Value = value
+
         // Validate value size
         if err := validateValue(req.Value); err != nil {
             BadRequest(w, err.Error())
             return
         }
+        if !writeValueCheck(w, req.Format, req.Value) {
+            return
+        }
+        if !writeSchemaCheck(w, ctx, req.ConnID, pendingValue{req.Key, []byte(req.Value)}) {
+            return
+        }
+        if !guardWrite(w, ctx, req.ConnID, keyTarget(req.Key)) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
@@ -259,13 +489,28 @@ func createKey(ctx *svc.ServiceContext) http.HandlerFunc {
         }
 
         var opts []clientv3.OpOption
-        if req.TTL > 0 {
+        var granted clientv3.LeaseID
+        if req.Lease != "" {
+            if req.TTL > 0 {
+                BadRequest(w, "ttl and lease are mutually exclusive")
+                return
+            }
+            id, err := parseLeaseID(req.Lease)
+            if err != nil {
+                BadRequest(w, err.Error())
+                return
+            }
+            opts = append(opts, clientv3.WithLease(id))
+        } else if req.TTL > 0 {
             lr, err := cli.Grant(r.Context(), req.TTL)
             if err != nil {
                 InternalError(w, err)
                 return
             }
             opts = append(opts, clientv3.WithLease(lr.ID))
+            // Revoked unless the put below succeeds
+            granted = lr.ID
+            defer func() { revokeUnusedLease(cli, granted) }()
         }
 
         txn := cli.Txn(r.Context()).If(cmps...).Then(
@@ -273,6 +518,10 @@ func createKey(ctx *svc.ServiceContext) http.HandlerFunc {
         )
         tResp, err := txn.Commit()
         if err != nil {
+            if isLeaseNotFound(err) {
+                BadRequest(w, "lease not found")
+                return
+            }
             InternalError(w, err)
             return
         }
@@ -281,7 +530,15 @@ func createKey(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.WriteJson(w, http.StatusConflict, map[string]string{"message": "key already exists"})
             return
         }
+        granted = 0
 
+        recordAudit(ctx, r, audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opCreate,
+            Keys:      []string{req.Key},
+            NewHash:   audit.HashValue([]byte(req.Value)),
+            Revision:  tResp.Header.Revision,
+        })
         httpx.Ok(w)
     }
 }
@@ -301,6 +558,10 @@ func deleteKey(ctx *svc.ServiceContext) http.HandlerFunc {
             BadRequest(w, err.Error())
             return
         }
+        // Children of the key are deleted too
+        if !guardWrite(w, ctx, connID, subtreeTarget(key)) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(connID)
         if !ok {
@@ -323,21 +584,37 @@ func deleteKey(ctx *svc.ServiceContext) http.HandlerFunc {
         }
 
         // If there are children (count > 0), delete with prefix to remove all descendants
+        var deleted int64
         if resp.Count > 0 {
-            _, err = cli.Delete(r.Context(), checkPrefix, clientv3.WithPrefix())
+            dResp, err := cli.Delete(r.Context(), checkPrefix, clientv3.WithPrefix())
             if err != nil {
                 InternalError(w, err)
                 return
             }
+            deleted = dResp.Deleted
         }
 
         // Also delete the exact key itself (in case it's a leaf node or a directory that stores data)
-        _, err = cli.Delete(r.Context(), key)
+        dResp, err := cli.Delete(r.Context(), key, clientv3.WithPrevKV())
         if err != nil {
             InternalError(w, err)
             return
         }
 
+        deleted += dResp.Deleted
+        if deleted > 0 {
+            entry := audit.Entry{
+                ConnID:    connID,
+                Operation: opDelete,
+                Keys:      []string{key},
+                Count:     int(deleted),
+                Revision:  dResp.Header.Revision,
+            }
+            if len(dResp.PrevKvs) > 0 {
+                entry.OldHash = audit.HashValue(dResp.PrevKvs[0].Value)
+            }
+            recordAudit(ctx, r, entry)
+        }
         httpx.Ok(w)
     }
 }
@@ -349,11 +626,22 @@ func renameKey(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.Error(w, err)
             return
         }
+        from, to := keyTarget(req.From), keyTarget(req.To)
+        if req.Recursive {
+            from, to = subtreeTarget(req.From), subtreeTarget(req.To)
+        }
+        if !guardWrite(w, ctx, req.ConnID, from, to) {
+            return
+        }
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
             httpx.WriteJson(w, http.StatusBadRequest, map[string]string{"message": "invalid connId or not connected"})
             return
         }
+        if req.Recursive {
+            renamePrefix(w, r, ctx, cli, req)
+            return
+        }
         // Prefetch source for value and lease
         gr, err := cli.Get(r.Context(), req.From)
         if err != nil {
@@ -366,6 +654,9 @@ func renameKey(ctx *svc.ServiceContext) http.HandlerFunc {
         }
         kv := gr.Kvs[0]
         val := string(kv.Value)
+        if !writeSchemaCheck(w, ctx, req.ConnID, pendingValue{req.To, kv.Value}) {
+            return
+        }
 
         var putOpts []clientv3.OpOption
         if kv.Lease > 0 {
@@ -393,6 +684,14 @@ func renameKey(ctx *svc.ServiceContext) http.HandlerFunc {
             httpx.WriteJson(w, http.StatusConflict, map[string]string{"message": msg})
             return
         }
+        recordAudit(ctx, r, audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opRename,
+            Keys:      []string{req.From, req.To},
+            OldHash:   audit.HashValue(kv.Value),
+            NewHash:   audit.HashValue(kv.Value),
+            Revision:  tResp.Header.Revision,
+        })
         httpx.Ok(w)
     }
 }
@@ -409,6 +708,13 @@ func batchDeleteKeys(ctx *svc.ServiceContext) http.HandlerFunc {
             BadRequest(w, "keys array is required and cannot be empty")
             return
         }
+        targets := make([]writeTarget, 0, len(req.Keys))
+        for _, key := range req.Keys {
+            targets = append(targets, keyTarget(key))
+        }
+        if !guardWrite(w, ctx, req.ConnID, targets...) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
@@ -428,12 +734,24 @@ func batchDeleteKeys(ctx *svc.ServiceContext) http.HandlerFunc {
 
         // Execute all deletes in a transaction (atomic)
         txn := cli.Txn(r.Context()).Then(ops...)
-        _, err := txn.Commit()
+        tResp, err := txn.Commit()
         if err != nil {
             InternalError(w, err)
             return
         }
 
+        var deleted int64
+        for _, op := range tResp.Responses {
+            deleted += op.GetResponseDeleteRange().Deleted
+        }
+        recordAudit(ctx, r, audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opBatchDelete,
+            Keys:      req.Keys,
+            Count:     int(deleted),
+            Revision:  tResp.Header.Revision,
+        })
+
         httpx.OkJson(w, map[string]int{"deleted": len(req.Keys)})
     }
 }
@@ -454,12 +772,31 @@ func copyKey(ctx *svc.ServiceContext) http.HandlerFunc {
             BadRequest(w, "invalid destination key: "+err.Error())
             return
         }
+        dst := keyTarget(req.To)
+        if req.Recursive {
+            dst = subtreeTarget(req.To)
+        }
+        dstConnID := req.ConnID
+        if req.TargetConnID != "" {
+            dstConnID = req.TargetConnID
+        }
+        if !guardWrite(w, ctx, dstConnID, dst) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
             BadRequest(w, "invalid connId or not connected")
             return
         }
+        if req.Recursive {
+            copyPrefix(w, r, ctx, cli, req)
+            return
+        }
+        if req.TargetConnID != "" && req.TargetConnID != req.ConnID {
+            BadRequest(w, "targetConnId requires recursive mode")
+            return
+        }
 
         // Fetch source key
         gr, err := cli.Get(r.Context(), req.From)
@@ -474,6 +811,9 @@ func copyKey(ctx *svc.ServiceContext) http.HandlerFunc {
 
         kv := gr.Kvs[0]
         val := string(kv.Value)
+        if !writeSchemaCheck(w, ctx, req.ConnID, pendingValue{req.To, kv.Value}) {
+            return
+        }
 
         var putOpts []clientv3.OpOption
         if kv.Lease > 0 {
@@ -486,6 +826,7 @@ func copyKey(ctx *svc.ServiceContext) http.HandlerFunc {
             cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(req.To), "=", 0))
         }
 
+        putOpts = append(putOpts, clientv3.WithPrevKV())
         txn := cli.Txn(r.Context()).If(cmps...).Then(
             clientv3.OpPut(req.To, val, putOpts...),
         )
@@ -504,6 +845,17 @@ func copyKey(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
+        entry := audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opCopy,
+            Keys:      []string{req.To},
+            NewHash:   audit.HashValue(kv.Value),
+            Revision:  tResp.Header.Revision,
+        }
+        if prev := tResp.Responses[0].GetResponsePut().PrevKv; prev != nil {
+            entry.OldHash = audit.HashValue(prev.Value)
+        }
+        recordAudit(ctx, r, entry)
         httpx.Ok(w)
     }
 }
@@ -516,8 +868,8 @@ func getKeyHistory(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
-        if req.Limit <= 0 || req.Limit > 100 {
-            req.Limit = 10
+        if req.Limit <= 0 || req.Limit > maxHistoryLimit {
+            req.Limit = defaultHistoryLimit
         }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
@@ -526,7 +878,7 @@ func getKeyHistory(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
-        // Get current key to find latest revision
+        // Get current key to find its creation and latest revision
         currentResp, err := cli.Get(r.Context(), req.Key)
         if err != nil {
             InternalError(w, err)
@@ -537,50 +889,42 @@ func getKeyHistory(ctx *svc.ServiceContext) http.HandlerFunc {
             return
         }
 
-        currentKv := currentResp.Kvs[0]
-        currentRev := currentKv.ModRevision
-
-        // Collect history by querying past revisions
-        var history []keyRevision
-        history = append(history, keyRevision{
-            Revision:    currentRev,
-            Value:       string(currentKv.Value),
-            ModRevision: currentKv.ModRevision,
-            CreateTime:  currentKv.CreateRevision,
-            Version:     currentKv.Version,
-        })
-
-        // Try to fetch older revisions (may fail if compacted)
-        for i := 1; i < req.Limit; i++ {
-            rev := currentRev - int64(i)
-            if rev < currentKv.CreateRevision {
-                break
-            }
+        versions, err := readVersions(r.Context(), cli, currentResp.Kvs[0], req.Limit)
+        if err != nil {
+            InternalError(w, err)
+            return
+        }
 
-            resp, err := cli.Get(r.Context(), req.Key, clientv3.WithRev(rev))
-            if err != nil || len(resp.Kvs) == 0 {
-                // Likely compacted or key didn't exist at this revision
-                continue
+        // Newest first; times are known for changes made through the manager
+        changes := auditedChanges(ctx.Audit, req.ConnID, req.Key)
+        history := make([]keyRevision, 0, len(versions.kvs))
+        for i := len(versions.kvs) - 1; i >= 0; i-- {
+            kv := versions.kvs[i]
+            item := keyRevision{
+                Revision:    kv.ModRevision,
+                ModRevision: kv.ModRevision,
+                CreateTime:  kv.CreateRevision,
+                Version:     kv.Version,
             }
-
-            kv := resp.Kvs[0]
-            // Only add if value actually changed
-            if len(history) == 0 || string(kv.Value) != history[len(history)-1].Value {
-                history = append(history, keyRevision{
-                    Revision:    rev,
-                    Value:       string(kv.Value),
-                    ModRevision: kv.ModRevision,
-                    CreateTime:  kv.CreateRevision,
-                    Version:     kv.Version,
-                })
+            item.Value, item.Encoding = encodeValue(kv.Value)
+            if e, ok := changes[kv.ModRevision]; ok {
+                t := e.Time
+                item.Time, item.User = &t, e.User
             }
+            history = append(history, item)
         }
 
-        httpx.OkJson(w, map[string]interface{}{
-            "key":     req.Key,
-            "history": history,
-            "note":    "history may be incomplete if etcd has compacted old revisions",
-        })
+        resp := historyResp{
+            Key:             req.Key,
+            History:         history,
+            CompactRevision: versions.compactRev,
+            Complete:        true,
+        }
+        if oldest := versions.kvs[0]; oldest.Version > 1 && len(versions.kvs) < req.Limit {
+            resp.Complete = false
+            resp.Note = fmt.Sprintf("versions before %d were removed by compaction at revision %d", oldest.Version, versions.compactRev)
+        }
+        httpx.OkJson(w, resp)
     }
 }
 
@@ -596,6 +940,9 @@ func rollbackKey(ctx *svc.ServiceContext) http.HandlerFunc {
             BadRequest(w, err.Error())
             return
         }
+        if !guardWrite(w, ctx, req.ConnID, keyTarget(req.Key)) {
+            return
+        }
 
         cli, ok := ctx.Manager.Client(req.ConnID)
         if !ok {
@@ -617,18 +964,35 @@ func rollbackKey(ctx *svc.ServiceContext) http.HandlerFunc {
         }
 
         oldValue := string(resp.Kvs[0].Value)
+        if !writeSchemaCheck(w, ctx, req.ConnID, pendingValue{req.Key, resp.Kvs[0].Value}) {
+            return
+        }
 
         // Write the old value as a new version (this is not a true rollback, but creates new revision)
-        _, err = cli.Put(r.Context(), req.Key, oldValue)
+        pResp, err := cli.Put(r.Context(), req.Key, oldValue, clientv3.WithPrevKV())
         if err != nil {
             InternalError(w, err)
             return
         }
 
+        entry := audit.Entry{
+            ConnID:    req.ConnID,
+            Operation: opRollback,
+            Keys:      []string{req.Key},
+            NewHash:   audit.HashValue(resp.Kvs[0].Value),
+            Revision:  pResp.Header.Revision,
+        }
+        if pResp.PrevKv != nil {
+            entry.OldHash = audit.HashValue(pResp.PrevKv.Value)
+        }
+        recordAudit(ctx, r, entry)
+
+        value, encoding := encodeValue(resp.Kvs[0].Value)
         httpx.OkJson(w, map[string]string{
             "message": "successfully rolled back to revision",
             "revision": fmt.Sprintf("%d", req.Revision),
-            "value":   oldValue,
+            "value":   value,
+            "encoding": encoding,
         })
     }
 }
\ No newline at end of file
@@ -0,0 +1,456 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	// maxTxnOps mirrors etcd's default --max-txn-ops limit. Compare, success
+	// and failure lists are each checked against it.
+	maxTxnOps = 128
+	// keysPerTxn is the number of keys moved per transaction. Each key needs
+	// up to two compares and two ops (put + delete).
+	keysPerTxn = maxTxnOps / 2
+)
+
+// Per-key statuses reported by bulk operations
+const (
+	bulkStatusMoved    = "moved"
+	bulkStatusCopied   = "copied"
+	bulkStatusImported = "imported"
+	bulkStatusSkipped  = "skipped"
+	bulkStatusConflict = "conflict"
+	bulkStatusFailed   = "failed"
+	bulkStatusPending  = "pending"
+)
+
+// Planned per-key actions, reported by copy and import (including dry-run)
+const (
+	bulkActionCreate    = "create"
+	bulkActionUpdate    = "update"
+	bulkActionUnchanged = "unchanged"
+	bulkActionSkip      = "skip"
+	bulkActionConflict  = "conflict"
+)
+
+// Conflict policies for writes onto existing destination keys
+const (
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictFail      = "fail"
+)
+
+// bulkKeyResult is the outcome of a bulk operation for a single key
+type bulkKeyResult struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Action string `json:"action,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkResp summarises a bulk operation. Each transaction chunk is atomic;
+// when a chunk fails, earlier chunks stay applied and later ones are not attempted.
+type bulkResp struct {
+	DryRun    bool            `json:"dryRun,omitempty"`
+	Total     int             `json:"total"`
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Complete  bool            `json:"complete"`
+	Revision  int64           `json:"revision,omitempty"` // revision of the last committed chunk
+	Results   []bulkKeyResult `json:"results"`
+}
+
+// subtreeBase strips the trailing slash from a directory key
+func subtreeBase(key string) string {
+	if key == "/" {
+		return ""
+	}
+	return strings.TrimSuffix(key, "/")
+}
+
+// subtreesOverlap reports whether one subtree contains the other
+func subtreesOverlap(a, b string) bool {
+	a, b = a+"/", b+"/"
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// fetchSubtree returns the key at base (if it holds data) and every key under base/,
+// read at a single revision.
+func fetchSubtree(ctx context.Context, cli *clientv3.Client, base string) ([]*mvccpb.KeyValue, error) {
+	kvs, _, err := fetchSubtreeAt(ctx, cli, base, 0)
+	return kvs, err
+}
+
+// fetchSubtreeAt is fetchSubtree as of revision rev, or the current revision when
+// rev is 0. It also returns the revision the subtree was read at.
+func fetchSubtreeAt(ctx context.Context, cli *clientv3.Client, base string, rev int64) ([]*mvccpb.KeyValue, int64, error) {
+	var opts []clientv3.OpOption
+	if rev > 0 {
+		opts = append(opts, clientv3.WithRev(rev))
+	}
+	ops := []clientv3.Op{clientv3.OpGet(base+"/", append(opts, clientv3.WithPrefix())...)}
+	if base != "" {
+		ops = append([]clientv3.Op{clientv3.OpGet(base, opts...)}, ops...)
+	}
+	resp, err := cli.Txn(ctx).Then(ops...).Commit()
+	if err != nil {
+		return nil, 0, err
+	}
+	var kvs []*mvccpb.KeyValue
+	for _, r := range resp.Responses {
+		kvs = append(kvs, r.GetResponseRange().Kvs...)
+	}
+	return kvs, resp.Header.Revision, nil
+}
+
+// chunkKVs splits kvs into slices of at most size elements
+func chunkKVs(kvs []*mvccpb.KeyValue, size int) [][]*mvccpb.KeyValue {
+	var chunks [][]*mvccpb.KeyValue
+	for len(kvs) > size {
+		chunks = append(chunks, kvs[:size])
+		kvs = kvs[size:]
+	}
+	if len(kvs) > 0 {
+		chunks = append(chunks, kvs)
+	}
+	return chunks
+}
+
+// renamePrefix moves a key and everything under it to a new location.
+// Sources are guarded by their ModRevision so concurrent edits abort the chunk.
+func renamePrefix(w http.ResponseWriter, r *http.Request, ctx *svc.ServiceContext, cli *clientv3.Client, req renameReq) {
+	if err := validateKey(req.From); err != nil {
+		BadRequest(w, "invalid source key: "+err.Error())
+		return
+	}
+	if err := validateKey(req.To); err != nil {
+		BadRequest(w, "invalid destination key: "+err.Error())
+		return
+	}
+	from, to := subtreeBase(req.From), subtreeBase(req.To)
+	if subtreesOverlap(from, to) {
+		BadRequest(w, "source and destination must not contain each other")
+		return
+	}
+
+	kvs, err := fetchSubtree(r.Context(), cli, from)
+	if err != nil {
+		InternalError(w, err)
+		return
+	}
+	if len(kvs) == 0 {
+		NotFound(w, "source key not found")
+		return
+	}
+
+	resp := bulkResp{Total: len(kvs), Results: make([]bulkKeyResult, len(kvs))}
+	writes := make([]pendingValue, len(kvs))
+	for i, kv := range kvs {
+		src := string(kv.Key)
+		resp.Results[i] = bulkKeyResult{From: src, To: to + strings.TrimPrefix(src, from), Status: bulkStatusPending}
+		writes[i] = pendingValue{resp.Results[i].To, kv.Value}
+	}
+	if !writeSchemaCheck(w, ctx, req.ConnID, writes...) {
+		return
+	}
+
+	// Refuse up front when destinations exist, so nothing is moved half-way
+	if !req.Overwrite {
+		existing, err := fetchSubtree(r.Context(), cli, to)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		taken := make(map[string]bool, len(existing))
+		for _, kv := range existing {
+			taken[string(kv.Key)] = true
+		}
+		conflicts := 0
+		for i := range resp.Results {
+			if taken[resp.Results[i].To] {
+				resp.Results[i].Status = bulkStatusConflict
+				resp.Results[i].Error = "destination exists"
+				conflicts++
+			}
+		}
+		if conflicts > 0 {
+			resp.Failed = conflicts
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+	}
+
+	offset := 0
+	for _, chunk := range chunkKVs(kvs, keysPerTxn) {
+		var cmps []clientv3.Cmp
+		var ops []clientv3.Op
+		for i, kv := range chunk {
+			dst := resp.Results[offset+i].To
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision))
+			if !req.Overwrite {
+				cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(dst), "=", 0))
+			}
+			var putOpts []clientv3.OpOption
+			if kv.Lease > 0 {
+				putOpts = append(putOpts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+			}
+			ops = append(ops, clientv3.OpPut(dst, string(kv.Value), putOpts...), clientv3.OpDelete(string(kv.Key)))
+		}
+
+		tResp, err := cli.Txn(r.Context()).If(cmps...).Then(ops...).Commit()
+		status, msg := bulkStatusMoved, ""
+		switch {
+		case err != nil:
+			status, msg = bulkStatusFailed, err.Error()
+		case !tResp.Succeeded:
+			status, msg = bulkStatusConflict, "source modified or destination created concurrently"
+		}
+		for i := range chunk {
+			resp.Results[offset+i].Status = status
+			resp.Results[offset+i].Error = msg
+		}
+		if status != bulkStatusMoved {
+			resp.Failed = len(chunk)
+			auditBulk(ctx, r, opRename, req.ConnID, resp)
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+		resp.Succeeded += len(chunk)
+		resp.Revision = tResp.Header.Revision
+		offset += len(chunk)
+	}
+
+	resp.Complete = true
+	auditBulk(ctx, r, opRename, req.ConnID, resp)
+	httpx.OkJson(w, resp)
+}
+
+// copyPrefix copies a key and everything under it to a destination prefix,
+// optionally on another connection. The source is read at a single revision.
+func copyPrefix(w http.ResponseWriter, r *http.Request, ctx *svc.ServiceContext, src *clientv3.Client, req copyReq) {
+	policy := req.Conflict
+	if policy == "" {
+		policy = conflictFail
+		if req.Overwrite {
+			policy = conflictOverwrite
+		}
+	}
+	if policy != conflictOverwrite && policy != conflictSkip && policy != conflictFail {
+		BadRequest(w, "conflict must be one of overwrite, skip, fail")
+		return
+	}
+
+	dstConnID := req.TargetConnID
+	if dstConnID == "" {
+		dstConnID = req.ConnID
+	}
+	dst, ok := ctx.Manager.Client(dstConnID)
+	if !ok {
+		BadRequest(w, "invalid targetConnId or not connected")
+		return
+	}
+	sameConn := dstConnID == req.ConnID
+
+	from, to := subtreeBase(req.From), subtreeBase(req.To)
+	if sameConn && subtreesOverlap(from, to) {
+		BadRequest(w, "source and destination must not contain each other")
+		return
+	}
+
+	kvs, err := fetchSubtree(r.Context(), src, from)
+	if err != nil {
+		InternalError(w, err)
+		return
+	}
+	if len(kvs) == 0 {
+		NotFound(w, "source key not found")
+		return
+	}
+	existing, err := fetchSubtree(r.Context(), dst, to)
+	if err != nil {
+		InternalError(w, err)
+		return
+	}
+	current := make(map[string][]byte, len(existing))
+	for _, kv := range existing {
+		current[string(kv.Key)] = kv.Value
+	}
+
+	// Plan every key before writing anything
+	resp := bulkResp{DryRun: req.DryRun, Total: len(kvs), Results: make([]bulkKeyResult, len(kvs))}
+	var pending []*mvccpb.KeyValue
+	var pendingIdx []int
+	conflicts := 0
+	for i, kv := range kvs {
+		dst := to + strings.TrimPrefix(string(kv.Key), from)
+		old, exists := current[dst]
+		res := planWrite(string(kv.Key), dst, old, exists, kv.Value, policy)
+		if res.Action == bulkActionConflict {
+			conflicts++
+		}
+		if res.Action == bulkActionCreate || res.Action == bulkActionUpdate {
+			pending = append(pending, kv)
+			pendingIdx = append(pendingIdx, i)
+		}
+		resp.Results[i] = res
+	}
+	writes := make([]pendingValue, len(pending))
+	for i, kv := range pending {
+		writes[i] = pendingValue{resp.Results[pendingIdx[i]].To, kv.Value}
+	}
+	if !writeSchemaCheck(w, ctx, dstConnID, writes...) {
+		return
+	}
+
+	if req.DryRun {
+		resp.Failed = conflicts
+		resp.Complete = conflicts == 0
+		httpx.OkJson(w, resp)
+		return
+	}
+	if conflicts > 0 {
+		resp.Failed = conflicts
+		httpx.WriteJson(w, http.StatusConflict, resp)
+		return
+	}
+
+	var leases map[int64]clientv3.LeaseID
+	if req.PreserveLease {
+		leases, err = mapLeases(r.Context(), src, dst, sameConn, pending)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+	}
+
+	offset := 0
+	for _, chunk := range chunkKVs(pending, maxTxnOps) {
+		var cmps []clientv3.Cmp
+		var ops []clientv3.Op
+		for i, kv := range chunk {
+			res := resp.Results[pendingIdx[offset+i]]
+			if res.Action == bulkActionCreate && policy != conflictOverwrite {
+				cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(res.To), "=", 0))
+			}
+			var putOpts []clientv3.OpOption
+			if lease, ok := leases[kv.Lease]; ok {
+				putOpts = append(putOpts, clientv3.WithLease(lease))
+			}
+			ops = append(ops, clientv3.OpPut(res.To, string(kv.Value), putOpts...))
+		}
+
+		tResp, err := dst.Txn(r.Context()).If(cmps...).Then(ops...).Commit()
+		status, msg := bulkStatusCopied, ""
+		switch {
+		case err != nil:
+			status, msg = bulkStatusFailed, err.Error()
+		case !tResp.Succeeded:
+			status, msg = bulkStatusConflict, "destination created concurrently"
+		}
+		for i := range chunk {
+			resp.Results[pendingIdx[offset+i]].Status = status
+			resp.Results[pendingIdx[offset+i]].Error = msg
+		}
+		if status != bulkStatusCopied {
+			// Leases granted on another connection are only kept for copied keys
+			if !sameConn {
+				revokeGrantedLeases(dst, leases, pending[:offset])
+			}
+			resp.Failed = len(chunk)
+			auditBulk(ctx, r, opCopy, dstConnID, resp)
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+		resp.Succeeded += len(chunk)
+		resp.Revision = tResp.Header.Revision
+		offset += len(chunk)
+	}
+
+	resp.Complete = true
+	auditBulk(ctx, r, opCopy, dstConnID, resp)
+	httpx.OkJson(w, resp)
+}
+
+// planWrite decides what writing value to key dst does under a conflict policy,
+// given the destination's current value
+func planWrite(src, dst string, old []byte, exists bool, value []byte, policy string) bulkKeyResult {
+	res := bulkKeyResult{From: src, To: dst, Status: bulkStatusPending}
+	switch {
+	case !exists:
+		res.Action = bulkActionCreate
+	case bytes.Equal(old, value):
+		res.Action = bulkActionUnchanged
+	case policy == conflictOverwrite:
+		res.Action = bulkActionUpdate
+	case policy == conflictSkip:
+		res.Action = bulkActionSkip
+	default:
+		res.Action = bulkActionConflict
+		res.Status = bulkStatusConflict
+		res.Error = "destination exists"
+	}
+	if res.Action == bulkActionUnchanged || res.Action == bulkActionSkip {
+		res.Status = bulkStatusSkipped
+	}
+	return res
+}
+
+// mapLeases returns the destination lease to attach for every source lease in kvs.
+// On the same connection leases are reused; across connections a new lease is
+// granted with the remaining TTL of the source lease.
+func mapLeases(ctx context.Context, src, dst *clientv3.Client, sameConn bool, kvs []*mvccpb.KeyValue) (map[int64]clientv3.LeaseID, error) {
+	leases := map[int64]clientv3.LeaseID{}
+	for _, kv := range kvs {
+		if kv.Lease == 0 {
+			continue
+		}
+		if _, ok := leases[kv.Lease]; ok {
+			continue
+		}
+		if sameConn {
+			leases[kv.Lease] = clientv3.LeaseID(kv.Lease)
+			continue
+		}
+		lt, err := src.TimeToLive(ctx, clientv3.LeaseID(kv.Lease))
+		if err != nil {
+			revokeGrantedLeases(dst, leases, nil)
+			return nil, fmt.Errorf("failed to read lease %x: %w", kv.Lease, err)
+		}
+		if lt.TTL <= 0 {
+			// Lease expired since the read; copy without it
+			continue
+		}
+		lr, err := dst.Grant(ctx, lt.TTL)
+		if err != nil {
+			revokeGrantedLeases(dst, leases, nil)
+			return nil, fmt.Errorf("failed to grant lease on target: %w", err)
+		}
+		leases[kv.Lease] = lr.ID
+	}
+	return leases, nil
+}
+
+// revokeGrantedLeases revokes the leases mapLeases granted on the target that no
+// key in copied was written with
+func revokeGrantedLeases(dst *clientv3.Client, leases map[int64]clientv3.LeaseID, copied []*mvccpb.KeyValue) {
+	used := map[int64]bool{}
+	for _, kv := range copied {
+		used[kv.Lease] = true
+	}
+	for srcLease, id := range leases {
+		if !used[srcLease] {
+			revokeUnusedLease(dst, id)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func TestSubtreesOverlap(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"siblings", "/app/a", "/app/b", false},
+		{"shared name prefix", "/app/a", "/app/ab", false},
+		{"same key", "/app/a", "/app/a", true},
+		{"destination inside source", "/app", "/app/backup", true},
+		{"source inside destination", "/app/backup", "/app", true},
+		{"root", "", "/app", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtreesOverlap(tt.a, tt.b); got != tt.want {
+				t.Errorf("subtreesOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkKVs(t *testing.T) {
+	kvs := make([]*mvccpb.KeyValue, 130)
+	for i := range kvs {
+		kvs[i] = &mvccpb.KeyValue{Key: []byte(fmt.Sprintf("/k/%d", i))}
+	}
+
+	chunks := chunkKVs(kvs, keysPerTxn)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if len(chunks[0]) != keysPerTxn || len(chunks[1]) != keysPerTxn || len(chunks[2]) != 2 {
+		t.Errorf("unexpected chunk sizes: %d, %d, %d", len(chunks[0]), len(chunks[1]), len(chunks[2]))
+	}
+	if string(chunks[2][1].Key) != "/k/129" {
+		t.Errorf("chunks lost ordering, last key = %s", chunks[2][1].Key)
+	}
+
+	if got := chunkKVs(nil, keysPerTxn); len(got) != 0 {
+		t.Errorf("expected no chunks for empty input, got %d", len(got))
+	}
+}
@@ -0,0 +1,613 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	defaultDiffLimit = 1000
+	maxDiffLimit     = 10000
+	// diffContext is the number of unchanged lines around each change in value diffs
+	diffContext = 3
+	// maxDiffCells bounds the line diff table; larger values are reported without a diff
+	maxDiffCells = 4 << 20
+)
+
+// Per-key statuses and actions of a sync
+const (
+	bulkStatusSynced = "synced"
+	bulkActionDelete = "delete"
+)
+
+// diffSide is one of the two ranges compared by a diff
+type diffSide struct {
+	ConnID string `json:"connId"`
+	Prefix string `json:"prefix,optional"`
+}
+
+type diffReq struct {
+	Left  diffSide `json:"left"`
+	Right diffSide `json:"right"`
+	// Ignore holds glob patterns (path.Match syntax) matched against keys relative
+	// to the prefixes. A pattern matching a directory ignores everything below it.
+	Ignore []string `json:"ignore,optional"`
+	// Limit caps the differences reported; counts always cover the whole range
+	Limit int `json:"limit,optional"`
+}
+
+// diffItem is one differing key. Key is relative to the prefixes in diffs
+// and a full key in restore previews.
+type diffItem struct {
+	Key              string `json:"key"`
+	LeftValue        string `json:"leftValue,omitempty"`
+	RightValue       string `json:"rightValue,omitempty"`
+	LeftEncoding     string `json:"leftEncoding,omitempty"`
+	RightEncoding    string `json:"rightEncoding,omitempty"`
+	LeftModRevision  int64  `json:"leftModRevision,omitempty"`
+	RightModRevision int64  `json:"rightModRevision,omitempty"`
+	// Diff is a unified line diff from left to right, set for changed text values
+	Diff string `json:"diff,omitempty"`
+}
+
+// leftItem reports a key that only exists on the left
+func leftItem(key string, kv *mvccpb.KeyValue) diffItem {
+	d := diffItem{Key: key, LeftModRevision: kv.ModRevision}
+	d.LeftValue, d.LeftEncoding = encodeValue(kv.Value)
+	return d
+}
+
+// rightItem reports a key that only exists on the right
+func rightItem(key string, kv *mvccpb.KeyValue) diffItem {
+	d := diffItem{Key: key, RightModRevision: kv.ModRevision}
+	d.RightValue, d.RightEncoding = encodeValue(kv.Value)
+	return d
+}
+
+// changedItem reports a key whose value differs between left and right
+func changedItem(key string, left, right *mvccpb.KeyValue) diffItem {
+	d := diffItem{
+		Key:              key,
+		LeftModRevision:  left.ModRevision,
+		RightModRevision: right.ModRevision,
+		Diff:             unifiedDiff(string(left.Value), string(right.Value)),
+	}
+	d.LeftValue, d.LeftEncoding = encodeValue(left.Value)
+	d.RightValue, d.RightEncoding = encodeValue(right.Value)
+	return d
+}
+
+type diffSideResp struct {
+	ConnID   string `json:"connId"`
+	Prefix   string `json:"prefix"`
+	Revision int64  `json:"revision"`
+}
+
+type diffCounts struct {
+	OnlyLeft  int `json:"onlyLeft"`
+	OnlyRight int `json:"onlyRight"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+	Ignored   int `json:"ignored"`
+}
+
+type diffResp struct {
+	Left      diffSideResp `json:"left"`
+	Right     diffSideResp `json:"right"`
+	Counts    diffCounts   `json:"counts"`
+	OnlyLeft  []diffItem   `json:"onlyLeft"`
+	OnlyRight []diffItem   `json:"onlyRight"`
+	Changed   []diffItem   `json:"changed"`
+	// Truncated is set when more than Limit differences were found
+	Truncated bool `json:"truncated"`
+}
+
+type syncReq struct {
+	Source diffSide `json:"source"`
+	Target diffSide `json:"target"`
+	// Keys are relative to the prefixes, as reported by diff
+	Keys []string `json:"keys"`
+	// TargetRevision is the target revision the diff was read at. Keys modified
+	// on the target after it are refused.
+	TargetRevision int64 `json:"targetRevision,optional"`
+}
+
+// rangeReader pages through the keys under a prefix at a single revision
+type rangeReader struct {
+	cli  *clientv3.Client
+	end  string
+	rev  int64
+	kvs  []*mvccpb.KeyValue
+	more bool
+}
+
+func newRangeReader(ctx context.Context, cli *clientv3.Client, prefix string) (*rangeReader, error) {
+	start, end := prefix, clientv3.GetPrefixRangeEnd(prefix)
+	if prefix == "" {
+		// Every key
+		start, end = "\x00", "\x00"
+	}
+	resp, err := cli.Get(ctx, start, clientv3.WithRange(end), clientv3.WithLimit(exportPageSize))
+	if err != nil {
+		return nil, err
+	}
+	return &rangeReader{cli: cli, end: end, rev: resp.Header.Revision, kvs: resp.Kvs, more: resp.More}, nil
+}
+
+// peek returns the next key without consuming it, or nil at the end of the range
+func (rr *rangeReader) peek() *mvccpb.KeyValue {
+	if len(rr.kvs) == 0 {
+		return nil
+	}
+	return rr.kvs[0]
+}
+
+// next consumes the key returned by peek, fetching the following page when needed
+func (rr *rangeReader) next(ctx context.Context) error {
+	last := rr.kvs[0]
+	rr.kvs = rr.kvs[1:]
+	if len(rr.kvs) > 0 || !rr.more {
+		return nil
+	}
+	resp, err := rr.cli.Get(ctx, string(last.Key)+"\x00", clientv3.WithRange(rr.end), clientv3.WithLimit(exportPageSize), clientv3.WithRev(rr.rev))
+	if err != nil {
+		return err
+	}
+	rr.kvs, rr.more = resp.Kvs, resp.More
+	return nil
+}
+
+// validateIgnorePatterns checks the syntax of diff ignore patterns
+func validateIgnorePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if p == "" {
+			return fmt.Errorf("ignore pattern cannot be empty")
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// ignoredKey reports whether a relative key, or one of its parent directories,
+// matches any of patterns. Leading slashes are not significant.
+func ignoredKey(patterns []string, rel string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	rel = strings.TrimLeft(rel, "/")
+	for {
+		for _, p := range patterns {
+			if ok, _ := path.Match(strings.TrimLeft(p, "/"), rel); ok {
+				return true
+			}
+		}
+		i := strings.LastIndex(rel, "/")
+		if i < 0 {
+			return false
+		}
+		rel = rel[:i]
+	}
+}
+
+// splitLines splits a value into lines, ignoring a final newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lineEdits returns a shortest edit script from a to b as lines prefixed with
+// ' ', '-' or '+', from a longest common subsequence table
+func lineEdits(a, b []string) []string {
+	n, m := len(a), len(b)
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []string
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, "-"+a[i])
+			i++
+		default:
+			ops = append(ops, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, "-"+a[i])
+	}
+	for ; j < m; j++ {
+		ops = append(ops, "+"+b[j])
+	}
+	return ops
+}
+
+// unifiedDiff returns a unified diff of two text values with diffContext lines
+// of context. It returns "" when either value is binary or too large to diff.
+func unifiedDiff(a, b string) string {
+	if !utf8.ValidString(a) || !utf8.ValidString(b) {
+		return ""
+	}
+	al, bl := splitLines(a), splitLines(b)
+	if (len(al)+1)*(len(bl)+1) > maxDiffCells {
+		return ""
+	}
+	ops := lineEdits(al, bl)
+
+	// aPos[k] and bPos[k] are the lines of a and b consumed before ops[k]
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op[0] != '+' {
+			aPos[k+1]++
+		}
+		if op[0] != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		c := start
+		for c < len(ops) && ops[c][0] == ' ' {
+			c++
+		}
+		if c == len(ops) {
+			break
+		}
+		from := c - diffContext
+		if from < start {
+			from = start
+		}
+		// Extend the hunk until a run of unchanged lines long enough to split on
+		end := c
+		for end < len(ops) {
+			if ops[end][0] != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run][0] == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end += diffContext
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		aStart, aLen := aPos[from]+1, aPos[end]-aPos[from]
+		bStart, bLen := bPos[from]+1, bPos[end]-bPos[from]
+		if aLen == 0 {
+			aStart--
+		}
+		if bLen == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, op := range ops[from:end] {
+			out.WriteString(op)
+			out.WriteByte('\n')
+		}
+		start = end
+	}
+	return out.String()
+}
+
+// diffPrefixes compares the keys under two prefixes, possibly on different
+// connections. Each side is read at a single revision.
+func diffPrefixes(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req diffReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if req.Left == req.Right {
+			BadRequest(w, "left and right must differ in connection or prefix")
+			return
+		}
+		if err := validateIgnorePatterns(req.Ignore); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		if req.Limit <= 0 {
+			req.Limit = defaultDiffLimit
+		}
+		if req.Limit > maxDiffLimit {
+			req.Limit = maxDiffLimit
+		}
+
+		leftCli, ok := ctx.Manager.Client(req.Left.ConnID)
+		if !ok {
+			BadRequest(w, "invalid left connId or not connected")
+			return
+		}
+		rightCli, ok := ctx.Manager.Client(req.Right.ConnID)
+		if !ok {
+			BadRequest(w, "invalid right connId or not connected")
+			return
+		}
+		left, err := newRangeReader(r.Context(), leftCli, req.Left.Prefix)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		right, err := newRangeReader(r.Context(), rightCli, req.Right.Prefix)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+
+		resp := diffResp{
+			Left:      diffSideResp{ConnID: req.Left.ConnID, Prefix: req.Left.Prefix, Revision: left.rev},
+			Right:     diffSideResp{ConnID: req.Right.ConnID, Prefix: req.Right.Prefix, Revision: right.rev},
+			OnlyLeft:  []diffItem{},
+			OnlyRight: []diffItem{},
+			Changed:   []diffItem{},
+		}
+		// Items are only built while under the limit; a changed item runs a line diff
+		reported := 0
+		report := func(list *[]diffItem, item func() diffItem) {
+			if reported >= req.Limit {
+				resp.Truncated = true
+				return
+			}
+			reported++
+			*list = append(*list, item())
+		}
+
+		// Both ranges are sorted and share their prefix, so relative keys can be merged
+		for {
+			lkv, rkv := left.peek(), right.peek()
+			if lkv == nil && rkv == nil {
+				break
+			}
+			var lrel, rrel string
+			if lkv != nil {
+				lrel = strings.TrimPrefix(string(lkv.Key), req.Left.Prefix)
+			}
+			if rkv != nil {
+				rrel = strings.TrimPrefix(string(rkv.Key), req.Right.Prefix)
+			}
+
+			switch {
+			case rkv == nil || (lkv != nil && lrel < rrel):
+				if ignoredKey(req.Ignore, lrel) {
+					resp.Counts.Ignored++
+				} else {
+					resp.Counts.OnlyLeft++
+					report(&resp.OnlyLeft, func() diffItem { return leftItem(lrel, lkv) })
+				}
+				err = left.next(r.Context())
+			case lkv == nil || rrel < lrel:
+				if ignoredKey(req.Ignore, rrel) {
+					resp.Counts.Ignored++
+				} else {
+					resp.Counts.OnlyRight++
+					report(&resp.OnlyRight, func() diffItem { return rightItem(rrel, rkv) })
+				}
+				err = right.next(r.Context())
+			default:
+				switch {
+				case ignoredKey(req.Ignore, lrel):
+					resp.Counts.Ignored++
+				case string(lkv.Value) == string(rkv.Value):
+					resp.Counts.Unchanged++
+				default:
+					resp.Counts.Changed++
+					report(&resp.Changed, func() diffItem { return changedItem(lrel, lkv, rkv) })
+				}
+				if err = left.next(r.Context()); err == nil {
+					err = right.next(r.Context())
+				}
+			}
+			if err != nil {
+				InternalError(w, err)
+				return
+			}
+		}
+
+		httpx.OkJson(w, resp)
+	}
+}
+
+// syncPrefixes copies selected differences from the source range to the target
+// range in one transaction. Keys missing on the source are deleted from the
+// target. The transaction fails if any target key changed after it was read.
+func syncPrefixes(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req syncReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if req.Source == req.Target {
+			BadRequest(w, "source and target must differ in connection or prefix")
+			return
+		}
+		if len(req.Keys) == 0 {
+			BadRequest(w, "keys cannot be empty")
+			return
+		}
+		if len(req.Keys) > maxTxnOps {
+			BadRequest(w, fmt.Sprintf("at most %d keys can be synced at once", maxTxnOps))
+			return
+		}
+
+		seen := make(map[string]bool, len(req.Keys))
+		var srcKeys, dstKeys []string
+		var targets []writeTarget
+		for _, rel := range req.Keys {
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			src, dst := req.Source.Prefix+rel, req.Target.Prefix+rel
+			if err := validateKey(src); err != nil {
+				BadRequest(w, fmt.Sprintf("invalid source key %q: %v", src, err))
+				return
+			}
+			if err := validateKey(dst); err != nil {
+				BadRequest(w, fmt.Sprintf("invalid target key %q: %v", dst, err))
+				return
+			}
+			srcKeys = append(srcKeys, src)
+			dstKeys = append(dstKeys, dst)
+			targets = append(targets, keyTarget(dst))
+		}
+		if !guardWrite(w, ctx, req.Target.ConnID, targets...) {
+			return
+		}
+
+		srcCli, ok := ctx.Manager.Client(req.Source.ConnID)
+		if !ok {
+			BadRequest(w, "invalid source connId or not connected")
+			return
+		}
+		dstCli, ok := ctx.Manager.Client(req.Target.ConnID)
+		if !ok {
+			BadRequest(w, "invalid target connId or not connected")
+			return
+		}
+		source, err := fetchKVs(r, srcCli, srcKeys)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		current, err := fetchKVs(r, dstCli, dstKeys)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+
+		resp := bulkResp{Total: len(srcKeys), Results: make([]bulkKeyResult, len(srcKeys))}
+		var cmps []clientv3.Cmp
+		var ops []clientv3.Op
+		var written []int
+		var writes []pendingValue
+		stale := 0
+		for i, src := range srcKeys {
+			dst := dstKeys[i]
+			res := bulkKeyResult{From: src, To: dst, Status: bulkStatusPending}
+			skv, inSource := source[src]
+			dkv, inTarget := current[dst]
+			switch {
+			case inSource && !inTarget:
+				res.Action = bulkActionCreate
+			case inSource && string(skv.Value) != string(dkv.Value):
+				res.Action = bulkActionUpdate
+			case !inSource && inTarget:
+				res.Action = bulkActionDelete
+			default:
+				res.Action, res.Status = bulkActionUnchanged, bulkStatusSkipped
+			}
+			if inTarget && req.TargetRevision > 0 && dkv.ModRevision > req.TargetRevision {
+				res.Status, res.Error = bulkStatusConflict, "target changed since the diff"
+				stale++
+			}
+			resp.Results[i] = res
+			if res.Status != bulkStatusPending {
+				continue
+			}
+
+			var modRev int64
+			if inTarget {
+				modRev = dkv.ModRevision
+			}
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(dst), "=", modRev))
+			if res.Action == bulkActionDelete {
+				ops = append(ops, clientv3.OpDelete(dst))
+			} else {
+				ops = append(ops, clientv3.OpPut(dst, string(skv.Value)))
+				writes = append(writes, pendingValue{dst, skv.Value})
+			}
+			written = append(written, i)
+		}
+		if stale > 0 {
+			resp.Failed = stale
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+		if len(written) == 0 {
+			resp.Complete = true
+			httpx.OkJson(w, resp)
+			return
+		}
+		// Values are checked against the schemas of the target connection
+		if !writeSchemaCheck(w, ctx, req.Target.ConnID, writes...) {
+			return
+		}
+
+		tResp, err := dstCli.Txn(r.Context()).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		if !tResp.Succeeded {
+			for _, i := range written {
+				resp.Results[i].Status = bulkStatusConflict
+				resp.Results[i].Error = "target modified concurrently"
+			}
+			resp.Failed = len(written)
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+		keys := make([]string, 0, len(written))
+		for _, i := range written {
+			resp.Results[i].Status = bulkStatusSynced
+			keys = append(keys, resp.Results[i].To)
+		}
+		resp.Succeeded = len(written)
+		resp.Revision = tResp.Header.Revision
+		resp.Complete = true
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.Target.ConnID,
+			Operation: opSync,
+			Keys:      keys,
+			Count:     len(keys),
+			Target:    req.Source.ConnID + ":" + req.Source.Prefix,
+			Revision:  resp.Revision,
+		})
+		httpx.OkJson(w, resp)
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"change", "a\nb\nc\n", "a\nB\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"from empty", "", "x", "@@ -0,0 +1,1 @@\n+x\n"},
+		{"to empty", "x\ny", "", "@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"binary", "\xff", "x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff(tt.a, tt.b); got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("separate hunks", func(t *testing.T) {
+		var a, b []string
+		for i := 1; i <= 20; i++ {
+			line := string(rune('a' + i))
+			a = append(a, line)
+			switch i {
+			case 2:
+				b = append(b, "X")
+			case 18:
+				b = append(b, "Y")
+			default:
+				b = append(b, line)
+			}
+		}
+		got := unifiedDiff(strings.Join(a, "\n"), strings.Join(b, "\n"))
+		hunks := strings.Count(got, "@@ -")
+		if hunks != 2 {
+			t.Fatalf("expected 2 hunks, got %d:\n%s", hunks, got)
+		}
+		if !strings.HasPrefix(got, "@@ -1,5 +1,5 @@\n") || !strings.Contains(got, "@@ -15,6 +15,6 @@\n") {
+			t.Errorf("unexpected hunk headers:\n%s", got)
+		}
+	})
+}
+
+func TestIgnoredKey(t *testing.T) {
+	patterns := []string{"*.bak", "cache", "/secrets/*/token"}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"/app.bak", true},
+		{"/dir/app.bak", false},
+		{"/cache", true},
+		{"/cache/a/b", true},
+		{"/cached", false},
+		{"/secrets/db/token", true},
+		{"secrets/db/password", false},
+		{"/app", false},
+	}
+	for _, tt := range tests {
+		if got := ignoredKey(patterns, tt.rel); got != tt.want {
+			t.Errorf("ignoredKey(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+	if ignoredKey(nil, "/a") {
+		t.Error("no patterns should ignore nothing")
+	}
+}
+
+func TestValidateIgnorePatterns(t *testing.T) {
+	if err := validateIgnorePatterns([]string{"*.bak", "a/[bc]"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateIgnorePatterns([]string{"a/[b"}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+	if err := validateIgnorePatterns([]string{""}); err == nil {
+		t.Error("expected error for empty pattern")
+	}
+}
@@ -0,0 +1,459 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/svc"
+)
+
+// Export formats
+const (
+	exportNested = "nested" // JSON tree, directories inferred from '/'
+	exportFlat   = "flat"   // JSON object of full key to value
+	exportYAML   = "yaml"   // YAML tree, same shape as nested
+	exportJSONL  = "jsonl"  // one etcdctl-style base64 key/value per line
+)
+
+const (
+	// exportPageSize is the number of keys read per range request
+	exportPageSize = 1000
+	// treeValueMember holds the value of a key that also has children
+	treeValueMember = "$value"
+	// base64ValueMember wraps a value that is not valid UTF-8 in the JSON and
+	// YAML formats, as {"$base64": "..."}
+	base64ValueMember = "$base64"
+)
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type exportReq struct {
+	ConnID string `form:"connId"`
+	Prefix string `form:"prefix,optional"`
+	Format string `form:"format,default=nested"`
+}
+
+// exportHeader is the first line of a jsonl export, named like etcdctl's -w json header
+type exportHeader struct {
+	Header struct {
+		ClusterID uint64 `json:"cluster_id"`
+		MemberID  uint64 `json:"member_id"`
+		Revision  int64  `json:"revision"`
+		RaftTerm  uint64 `json:"raft_term"`
+	} `json:"header"`
+	Prefix string `json:"prefix"`
+}
+
+// exportKV is one jsonl line. Field names and base64 encoding of key and
+// value follow etcdctl's -w json output.
+type exportKV struct {
+	Key            string `json:"key"`
+	CreateRevision int64  `json:"create_revision"`
+	ModRevision    int64  `json:"mod_revision"`
+	Version        int64  `json:"version"`
+	Value          string `json:"value"`
+	Lease          int64  `json:"lease,omitempty"`
+}
+
+// exportWriter serialises keys as they are read. Keys arrive in sorted order.
+type exportWriter interface {
+	// writeKV writes one key. hasChildren reports whether other keys live under key + "/".
+	writeKV(kv *mvccpb.KeyValue, hasChildren bool) error
+	close() error
+}
+
+// needsChildren reports whether the format has to know which keys also have children
+func needsChildren(format string) bool {
+	return format == exportNested || format == exportYAML
+}
+
+// exportFilename returns the download name for a prefix export
+func exportFilename(prefix, format string) string {
+	name := strings.Trim(unsafeFilenameChars.ReplaceAllString(prefix, "_"), "_")
+	if name == "" {
+		name = "root"
+	}
+	ext := "json"
+	switch format {
+	case exportYAML:
+		ext = "yaml"
+	case exportJSONL:
+		ext = "jsonl"
+	}
+	return "etcd-export-" + name + "." + ext
+}
+
+// exportKeys streams every key under prefix in the requested format. All pages
+// are read at the revision of the first one, so the export is a consistent snapshot.
+func exportKeys(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req exportReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		switch req.Format {
+		case exportNested, exportFlat, exportYAML, exportJSONL:
+		default:
+			BadRequest(w, "format must be one of nested, flat, yaml, jsonl")
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		start, end := req.Prefix, clientv3.GetPrefixRangeEnd(req.Prefix)
+		if req.Prefix == "" {
+			// Every key
+			start, end = "\x00", "\x00"
+		}
+		// Read the first page before writing headers so connection errors get a proper status
+		first, err := cli.Get(r.Context(), start, clientv3.WithRange(end), clientv3.WithLimit(exportPageSize))
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		rev := first.Header.Revision
+
+		var ew exportWriter
+		contentType := "application/json"
+		switch req.Format {
+		case exportNested:
+			ew = newTreeEncoder(w, treeRoot(req.Prefix), false)
+		case exportYAML:
+			ew = newTreeEncoder(w, treeRoot(req.Prefix), true)
+			contentType = "application/yaml"
+		case exportFlat:
+			ew = &flatEncoder{w: w}
+		case exportJSONL:
+			contentType = "application/x-ndjson"
+			var h exportHeader
+			h.Header.ClusterID = first.Header.ClusterId
+			h.Header.MemberID = first.Header.MemberId
+			h.Header.Revision = rev
+			h.Header.RaftTerm = first.Header.RaftTerm
+			h.Prefix = req.Prefix
+			ew = &jsonlEncoder{w: w, header: h}
+		}
+
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(req.Prefix, req.Format)))
+		w.Header().Set("X-Etcd-Revision", fmt.Sprintf("%d", rev))
+		w.WriteHeader(http.StatusOK)
+		flusher, _ := w.(http.Flusher)
+
+		// Errors after the headers cannot change the status; the output is left
+		// truncated (and, for JSON and YAML, unterminated) so it is not mistaken for a full export.
+		resp := first
+		for {
+			var children map[string]bool
+			if needsChildren(req.Format) {
+				children, err = keysWithChildren(r.Context(), cli, resp.Kvs, resp.More, rev)
+				if err != nil {
+					logx.Errorf("export of %q aborted: %v", req.Prefix, err)
+					return
+				}
+			}
+			for _, kv := range resp.Kvs {
+				if err := ew.writeKV(kv, children[string(kv.Key)]); err != nil {
+					logx.Errorf("export of %q aborted: %v", req.Prefix, err)
+					return
+				}
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			if !resp.More || len(resp.Kvs) == 0 {
+				break
+			}
+			next := string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+			resp, err = cli.Get(r.Context(), next, clientv3.WithRange(end), clientv3.WithLimit(exportPageSize), clientv3.WithRev(rev))
+			if err != nil {
+				logx.Errorf("export of %q aborted: %v", req.Prefix, err)
+				return
+			}
+		}
+		if err := ew.close(); err != nil {
+			logx.Errorf("export of %q aborted: %v", req.Prefix, err)
+		}
+	}
+}
+
+// keysWithChildren returns the keys of a sorted page that also have keys under
+// key + "/". Children inside the page are found by search; only keys whose
+// children would sort after the page are looked up, in batched count-only reads.
+func keysWithChildren(ctx context.Context, cli *clientv3.Client, kvs []*mvccpb.KeyValue, more bool, rev int64) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(kvs) == 0 {
+		return out, nil
+	}
+	last := string(kvs[len(kvs)-1].Key)
+	var peek []string
+	for i, kv := range kvs {
+		dir := string(kv.Key) + "/"
+		j := i + 1 + sort.Search(len(kvs)-i-1, func(n int) bool { return string(kvs[i+1+n].Key) >= dir })
+		if j < len(kvs) && strings.HasPrefix(string(kvs[j].Key), dir) {
+			out[string(kv.Key)] = true
+		} else if more && dir > last {
+			peek = append(peek, string(kv.Key))
+		}
+	}
+	for len(peek) > 0 {
+		n := len(peek)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		ops := make([]clientv3.Op, n)
+		for j, k := range peek[:n] {
+			ops[j] = clientv3.OpGet(k+"/", clientv3.WithPrefix(), clientv3.WithCountOnly(), clientv3.WithRev(rev))
+		}
+		resp, err := cli.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for j, k := range peek[:n] {
+			if resp.Responses[j].GetResponseRange().Count > 0 {
+				out[k] = true
+			}
+		}
+		peek = peek[n:]
+	}
+	return out, nil
+}
+
+// treeRoot is the part of prefix stripped from keys in tree exports: everything
+// up to and including its last '/'
+func treeRoot(prefix string) string {
+	return prefix[:strings.LastIndex(prefix, "/")+1]
+}
+
+// treeEncoder writes sorted keys as a nested JSON or YAML document without
+// holding the tree in memory. Keys under one directory are contiguous in
+// sort order, so a stack of open directories is enough.
+type treeEncoder struct {
+	w     io.Writer
+	yaml  bool
+	root  string
+	stack []string
+	// empty[d] is true while the object at depth d has no members (JSON commas)
+	empty []bool
+	// pending holds values of keys that also have children, by directory key,
+	// until that directory is opened
+	pending map[string][]byte
+	err     error
+}
+
+func newTreeEncoder(w io.Writer, root string, yaml bool) *treeEncoder {
+	return &treeEncoder{w: w, yaml: yaml, root: root, empty: []bool{true}, pending: map[string][]byte{}}
+}
+
+func (t *treeEncoder) printf(format string, args ...interface{}) {
+	if t.err == nil {
+		_, t.err = fmt.Fprintf(t.w, format, args...)
+	}
+}
+
+// quote renders a string as a JSON string, which is also a valid YAML double-quoted scalar
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// exportValue renders a value for the JSON and YAML formats. Values that are
+// not valid UTF-8 are wrapped in a base64 object, which is also valid YAML.
+func exportValue(value []byte) string {
+	s, encoding := encodeValue(value)
+	if encoding == encodingBase64 {
+		return fmt.Sprintf("{%s: %s}", quote(base64ValueMember), quote(s))
+	}
+	return quote(s)
+}
+
+func (t *treeEncoder) indent() string {
+	return strings.Repeat("  ", len(t.stack))
+}
+
+// member starts a member of the innermost open object
+func (t *treeEncoder) member(name string) {
+	d := len(t.stack)
+	if t.yaml {
+		t.printf("%s%s:", t.indent(), quote(name))
+	} else {
+		switch {
+		case d == 0 && t.empty[0]:
+			t.printf("{")
+		case !t.empty[d]:
+			t.printf(",")
+		}
+		t.printf("\n  %s%s: ", t.indent(), quote(name))
+	}
+	t.empty[d] = false
+}
+
+func (t *treeEncoder) open(name string) {
+	t.member(name)
+	if t.yaml {
+		t.printf("\n")
+	} else {
+		t.printf("{")
+	}
+	t.stack = append(t.stack, name)
+	t.empty = append(t.empty, true)
+	if v, ok := t.pending[t.dirKey()]; ok {
+		delete(t.pending, t.dirKey())
+		t.leaf(treeValueMember, v)
+	}
+}
+
+func (t *treeEncoder) closeDir() {
+	empty := t.empty[len(t.empty)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+	t.empty = t.empty[:len(t.empty)-1]
+	if !t.yaml {
+		if !empty {
+			t.printf("\n  %s", t.indent())
+		}
+		t.printf("}")
+	}
+}
+
+func (t *treeEncoder) leaf(name string, value []byte) {
+	t.member(name)
+	if t.yaml {
+		t.printf(" %s\n", exportValue(value))
+	} else {
+		t.printf("%s", exportValue(value))
+	}
+}
+
+// dirKey is the etcd key prefix of the innermost open directory
+func (t *treeEncoder) dirKey() string {
+	return t.root + strings.Join(t.stack, "/") + "/"
+}
+
+func (t *treeEncoder) writeKV(kv *mvccpb.KeyValue, hasChildren bool) error {
+	key := string(kv.Key)
+	if hasChildren {
+		// Written as the directory's $value member once the directory opens
+		t.pending[key+"/"] = kv.Value
+		return t.err
+	}
+	segs := strings.Split(strings.TrimPrefix(key, t.root), "/")
+	dirs, name := segs[:len(segs)-1], segs[len(segs)-1]
+
+	common := 0
+	for common < len(t.stack) && common < len(dirs) && t.stack[common] == dirs[common] {
+		common++
+	}
+	for len(t.stack) > common {
+		t.closeDir()
+	}
+	for _, d := range dirs[common:] {
+		t.open(d)
+	}
+	t.leaf(name, kv.Value)
+	return t.err
+}
+
+func (t *treeEncoder) close() error {
+	for len(t.stack) > 0 {
+		t.closeDir()
+	}
+	if t.yaml {
+		if t.empty[0] {
+			t.printf("{}\n")
+		}
+		return t.err
+	}
+	if t.empty[0] {
+		t.printf("{}\n")
+	} else {
+		t.printf("\n}\n")
+	}
+	return t.err
+}
+
+// flatEncoder writes a JSON object of full key to value
+type flatEncoder struct {
+	w       io.Writer
+	started bool
+}
+
+func (f *flatEncoder) writeKV(kv *mvccpb.KeyValue, _ bool) error {
+	sep := ",\n  "
+	if !f.started {
+		sep = "{\n  "
+		f.started = true
+	}
+	_, err := fmt.Fprintf(f.w, "%s%s: %s", sep, quote(string(kv.Key)), exportValue(kv.Value))
+	return err
+}
+
+func (f *flatEncoder) close() error {
+	if !f.started {
+		_, err := io.WriteString(f.w, "{}\n")
+		return err
+	}
+	_, err := io.WriteString(f.w, "\n}\n")
+	return err
+}
+
+// jsonlEncoder writes a header line followed by one line per key. Keys and
+// values are base64 encoded, so binary data survives the round trip.
+type jsonlEncoder struct {
+	w       io.Writer
+	header  exportHeader
+	started bool
+}
+
+func (j *jsonlEncoder) writeLine(v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(line, '\n'))
+	return err
+}
+
+func (j *jsonlEncoder) writeKV(kv *mvccpb.KeyValue, _ bool) error {
+	if !j.started {
+		j.started = true
+		if err := j.writeLine(j.header); err != nil {
+			return err
+		}
+	}
+	return j.writeLine(exportKV{
+		Key:            base64.StdEncoding.EncodeToString(kv.Key),
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Value:          base64.StdEncoding.EncodeToString(kv.Value),
+		Lease:          kv.Lease,
+	})
+}
+
+func (j *jsonlEncoder) close() error {
+	if !j.started {
+		j.started = true
+		return j.writeLine(j.header)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"gopkg.in/yaml.v2"
+)
+
+// encodeTree runs keys (sorted) through a treeEncoder. Keys in withChildren
+// are reported as also having children.
+func encodeTree(t *testing.T, root string, isYAML bool, keys []string, values map[string]string, withChildren map[string]bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := newTreeEncoder(&buf, root, isYAML)
+	for _, k := range keys {
+		if err := enc.writeKV(&mvccpb.KeyValue{Key: []byte(k), Value: []byte(values[k])}, withChildren[k]); err != nil {
+			t.Fatalf("writeKV failed: %v", err)
+		}
+	}
+	if err := enc.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestTreeEncoder(t *testing.T) {
+	// Sorted as etcd returns them: "/app/a" sorts before its sibling "/app/a-b"
+	// and both before the children of "/app/a"
+	keys := []string{"/app/a", "/app/a-b", "/app/a/x", "/app/a/y/z", "/app/b", "/app/c/"}
+	values := map[string]string{
+		"/app/a":     "dir value",
+		"/app/a-b":   "1",
+		"/app/a/x":   "quote \" and\nnewline",
+		"/app/a/y/z": "<tag>",
+		"/app/b":     "2",
+		"/app/c/":    "trailing slash",
+	}
+	withChildren := map[string]bool{"/app/a": true}
+	want := map[string]interface{}{
+		"a-b": "1",
+		"a": map[string]interface{}{
+			"$value": "dir value",
+			"x":      "quote \" and\nnewline",
+			"y":      map[string]interface{}{"z": "<tag>"},
+		},
+		"b": "2",
+		"c": map[string]interface{}{"": "trailing slash"},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		out := encodeTree(t, "/app/", false, keys, values, withChildren)
+		var got map[string]interface{}
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, out)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		out := encodeTree(t, "/app/", true, keys, values, withChildren)
+		var got map[string]interface{}
+		if err := yaml.Unmarshal(out, &got); err != nil {
+			t.Fatalf("invalid YAML: %v\n%s", err, out)
+		}
+		// Round trip through JSON to normalise yaml.v2's map[interface{}]interface{}
+		wantYAML, _ := yaml.Marshal(want)
+		var normalised map[string]interface{}
+		_ = yaml.Unmarshal(wantYAML, &normalised)
+		if !reflect.DeepEqual(got, normalised) {
+			t.Errorf("got %v, want %v", got, normalised)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		if out := string(encodeTree(t, "", false, nil, nil, nil)); out != "{}\n" {
+			t.Errorf("empty JSON export = %q", out)
+		}
+		if out := string(encodeTree(t, "", true, nil, nil, nil)); out != "{}\n" {
+			t.Errorf("empty YAML export = %q", out)
+		}
+	})
+}
+
+func TestFlatEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := &flatEncoder{w: &buf}
+	_ = enc.writeKV(&mvccpb.KeyValue{Key: []byte("/a"), Value: []byte("1")}, false)
+	_ = enc.writeKV(&mvccpb.KeyValue{Key: []byte("/a/b"), Value: []byte("2")}, false)
+	if err := enc.close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if want := map[string]string{"/a": "1", "/a/b": "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTreeRootAndFilename(t *testing.T) {
+	tests := []struct {
+		prefix, root, file string
+	}{
+		{"", "", "etcd-export-root.json"},
+		{"/", "/", "etcd-export-root.json"},
+		{"/app/", "/app/", "etcd-export-app.json"},
+		{"/app/db", "/app/", "etcd-export-app_db.json"},
+		{"config", "", "etcd-export-config.json"},
+	}
+	for _, tt := range tests {
+		if got := treeRoot(tt.prefix); got != tt.root {
+			t.Errorf("treeRoot(%q) = %q, want %q", tt.prefix, got, tt.root)
+		}
+		if got := exportFilename(tt.prefix, exportNested); got != tt.file {
+			t.Errorf("exportFilename(%q) = %q, want %q", tt.prefix, got, tt.file)
+		}
+	}
+	if got := exportFilename("/app/", exportJSONL); got != "etcd-export-app.jsonl" {
+		t.Errorf("exportFilename jsonl = %q", got)
+	}
+}
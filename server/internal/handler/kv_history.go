@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+)
+
+const (
+	defaultHistoryLimit = 10
+	maxHistoryLimit     = 100
+	// historyTimeout bounds the reads of a key's versions
+	historyTimeout = 30 * time.Second
+)
+
+// keyVersions holds the newest versions of a key, oldest first
+type keyVersions struct {
+	kvs []*mvccpb.KeyValue
+	// compactRev is set when older versions were compacted away
+	compactRev int64
+}
+
+// readVersions reads up to limit versions of the current incarnation of a key,
+// walking back from cur with one range read per version. When the walk reaches
+// the compaction boundary, compactRev reports it.
+func readVersions(ctx context.Context, cli *clientv3.Client, cur *mvccpb.KeyValue, limit int) (keyVersions, error) {
+	ctx, cancel := context.WithTimeout(ctx, historyTimeout)
+	defer cancel()
+
+	key := string(cur.Key)
+	out, err := walkVersions(cur, limit, func(rev int64) (*mvccpb.KeyValue, error) {
+		resp, err := cli.Get(ctx, key, clientv3.WithRev(rev))
+		if err != nil || len(resp.Kvs) == 0 {
+			return nil, err
+		}
+		return resp.Kvs[0], nil
+	})
+	if errors.Is(err, rpctypes.ErrCompacted) {
+		out.compactRev, err = compactedAt(ctx, cli, key, out.kvs[0].ModRevision-1)
+	}
+	if err != nil {
+		return out, fmt.Errorf("failed to read the history of %q: %w", key, err)
+	}
+	return out, nil
+}
+
+// walkVersions collects up to limit versions of cur's key, starting at cur and
+// reading each older version at the revision just before its successor was
+// written. It stops at the version that created the key, and on an error from
+// get, such as a compacted revision, it returns the versions read so far with
+// that error.
+func walkVersions(cur *mvccpb.KeyValue, limit int, get func(rev int64) (*mvccpb.KeyValue, error)) (keyVersions, error) {
+	newest := []*mvccpb.KeyValue{cur}
+	var err error
+	for kv := cur; len(newest) < limit && kv.Version > 1; {
+		var prev *mvccpb.KeyValue
+		prev, err = get(kv.ModRevision - 1)
+		if err != nil || prev == nil || prev.CreateRevision != cur.CreateRevision {
+			break
+		}
+		newest = append(newest, prev)
+		kv = prev
+	}
+	var out keyVersions
+	for i := len(newest) - 1; i >= 0; i-- {
+		out.kvs = append(out.kvs, newest[i])
+	}
+	return out, err
+}
+
+// compactedAt returns the compaction revision that made rev unreadable. A watch
+// from a compacted revision is cancelled with it straight away.
+func compactedAt(ctx context.Context, cli *clientv3.Client, key string, rev int64) (int64, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for resp := range cli.Watch(wctx, key, clientv3.WithRev(rev)) {
+		if resp.CompactRevision != 0 {
+			return resp.CompactRevision, nil
+		}
+		if err := resp.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("watch closed before reporting the compaction revision: %w", ctx.Err())
+}
+
+// auditedChanges maps the revisions of audited changes to key to their entries
+func auditedChanges(log *audit.Log, connID, key string) map[int64]audit.Entry {
+	entries, err := log.Query(audit.Filter{ConnID: connID, Prefix: key, WithRevision: true, Limit: maxAuditLimit})
+	if err != nil {
+		return nil
+	}
+	out := map[int64]audit.Entry{}
+	for _, e := range entries {
+		for _, k := range e.Keys {
+			if k == key {
+				out[e.Revision] = e
+				break
+			}
+		}
+	}
+	return out
+}
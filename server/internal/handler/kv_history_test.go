@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"etcd-manager/server/internal/audit"
+)
+
+// keyRevisions fakes the reads of a key whose versions were written at
+// the given revisions, with nothing readable before compactRev
+func keyRevisions(revs []int64, compactRev int64, reads *int) (*mvccpb.KeyValue, func(int64) (*mvccpb.KeyValue, error)) {
+	kvs := make([]*mvccpb.KeyValue, len(revs))
+	for i, rev := range revs {
+		kvs[i] = &mvccpb.KeyValue{Key: []byte("/k"), CreateRevision: revs[0], ModRevision: rev, Version: int64(i + 1)}
+	}
+	get := func(rev int64) (*mvccpb.KeyValue, error) {
+		*reads++
+		if rev < compactRev {
+			return nil, rpctypes.ErrCompacted
+		}
+		var found *mvccpb.KeyValue
+		for _, kv := range kvs {
+			if kv.ModRevision <= rev {
+				found = kv
+			}
+		}
+		return found, nil
+	}
+	return kvs[len(kvs)-1], get
+}
+
+func TestWalkVersions(t *testing.T) {
+	var reads int
+	cur, get := keyRevisions([]int64{3, 8, 9, 15, 20}, 0, &reads)
+	got, err := walkVersions(cur, 3, get)
+	if err != nil {
+		t.Fatalf("walkVersions failed: %v", err)
+	}
+	if reads != 2 {
+		t.Errorf("expected 2 reads for 3 versions, got %d", reads)
+	}
+	if len(got.kvs) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(got.kvs))
+	}
+	for i, kv := range got.kvs {
+		if kv.Version != int64(i+3) {
+			t.Errorf("kvs[%d].Version = %d, want %d", i, kv.Version, i+3)
+		}
+	}
+
+	reads = 0
+	got, err = walkVersions(cur, 10, get)
+	if err != nil || len(got.kvs) != 5 || got.kvs[0].Version != 1 {
+		t.Errorf("expected all 5 versions, got %d (err %v)", len(got.kvs), err)
+	}
+	if reads != 4 {
+		t.Errorf("expected the walk to stop at the first version after 4 reads, got %d", reads)
+	}
+}
+
+func TestWalkVersionsStopsAtCompaction(t *testing.T) {
+	var reads int
+	cur, get := keyRevisions([]int64{3, 8, 9, 15, 20}, 12, &reads)
+	got, err := walkVersions(cur, 10, get)
+	if !errors.Is(err, rpctypes.ErrCompacted) {
+		t.Fatalf("expected ErrCompacted, got %v", err)
+	}
+	// Version 3 was live at the boundary and stays readable, version 2 is gone
+	if len(got.kvs) != 3 || got.kvs[0].Version != 3 {
+		t.Errorf("expected versions 3 to 5, got %+v", got.kvs)
+	}
+}
+
+func TestWalkVersionsStopsAtRecreation(t *testing.T) {
+	var reads int
+	cur := &mvccpb.KeyValue{Key: []byte("/k"), CreateRevision: 10, ModRevision: 10, Version: 1}
+	got, err := walkVersions(cur, 10, func(int64) (*mvccpb.KeyValue, error) {
+		reads++
+		return nil, nil
+	})
+	if err != nil || len(got.kvs) != 1 || reads != 0 {
+		t.Errorf("expected only the current version without reads, got %d versions, %d reads (err %v)", len(got.kvs), reads, err)
+	}
+
+	// A previous incarnation of the key is not part of the history
+	cur = &mvccpb.KeyValue{Key: []byte("/k"), CreateRevision: 10, ModRevision: 12, Version: 2}
+	got, err = walkVersions(cur, 10, func(int64) (*mvccpb.KeyValue, error) {
+		return &mvccpb.KeyValue{Key: []byte("/k"), CreateRevision: 4, ModRevision: 6, Version: 3}, nil
+	})
+	if err != nil || len(got.kvs) != 1 {
+		t.Errorf("expected only the current version, got %d (err %v)", len(got.kvs), err)
+	}
+}
+
+func TestAuditedChanges(t *testing.T) {
+	log, err := audit.New(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatalf("audit.New failed: %v", err)
+	}
+	defer log.Close()
+	entries := []audit.Entry{
+		{ConnID: "c1", Operation: opPut, Keys: []string{"/app/a"}, User: "alice", Revision: 5},
+		{ConnID: "c1", Operation: opPut, Keys: []string{"/app/ab"}, Revision: 6},
+		{ConnID: "c2", Operation: opPut, Keys: []string{"/app/a"}, Revision: 7},
+		{ConnID: "c1", Operation: opImport, Keys: []string{"/app/x", "/app/a"}, Revision: 8},
+	}
+	for _, e := range entries {
+		if err := log.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	got := auditedChanges(log, "c1", "/app/a")
+	if len(got) != 2 || got[5].User != "alice" || got[8].Operation != opImport {
+		t.Errorf("unexpected changes: %+v", got)
+	}
+	if len(auditedChanges(nil, "c1", "/app/a")) != 0 {
+		t.Error("expected no changes without an audit log")
+	}
+}
@@ -0,0 +1,487 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"gopkg.in/yaml.v2"
+
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	// maxImportSize bounds the request body of an import
+	maxImportSize = 64 << 20
+	// maxImportErrors caps the invalid entries listed in an error response
+	maxImportErrors = 20
+	// maxImportLine bounds a single jsonl line: a base64 key and a 1MB value
+	maxImportLine = 4 << 20
+)
+
+type importReq struct {
+	ConnID string `form:"connId"`
+	// Prefix is prepended to every imported key. For nested and yaml dumps it is
+	// the directory the tree is rooted at.
+	Prefix string `form:"prefix,optional"`
+	Format string `form:"format,default=nested"`
+	// StripPrefix is removed from the keys of flat and jsonl dumps before Prefix is added
+	StripPrefix string `form:"stripPrefix,optional"`
+	Conflict    string `form:"conflict,default=fail"`
+	DryRun      bool   `form:"dryRun,optional"`
+}
+
+// importEntry is one key read from a dump
+type importEntry struct {
+	Source string // key, or tree path, in the dump
+	Key    string // destination key
+	Value  []byte
+}
+
+// importKV is the part of an export jsonl line that import uses
+type importKV struct {
+	Header json.RawMessage `json:"header"`
+	Key    *string         `json:"key"`
+	Value  string          `json:"value"`
+}
+
+// parseImport reads the entries of a dump. Source is set; Key is left to the caller.
+func parseImport(format string, data []byte) ([]importEntry, error) {
+	switch format {
+	case exportNested:
+		var tree interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&tree); err != nil {
+			return nil, err
+		}
+		return flattenTree(tree)
+	case exportYAML:
+		var tree interface{}
+		if err := yaml.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+		return flattenTree(tree)
+	case exportFlat:
+		var flat map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&flat); err != nil {
+			return nil, err
+		}
+		if flat == nil {
+			return nil, fmt.Errorf("expected a JSON object of key to value")
+		}
+		entries := make([]importEntry, 0, len(flat))
+		for k, v := range flat {
+			value, err := importValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("%q: %w", k, err)
+			}
+			entries = append(entries, importEntry{Source: k, Value: value})
+		}
+		return entries, nil
+	case exportJSONL:
+		return parseJSONL(data)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// parseJSONL reads an export jsonl dump. Header lines and blank lines are skipped;
+// revisions and leases are ignored.
+func parseJSONL(data []byte) ([]importEntry, error) {
+	var entries []importEntry
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), maxImportLine)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var kv importKV
+		if err := json.Unmarshal(sc.Bytes(), &kv); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if kv.Header != nil {
+			continue
+		}
+		if kv.Key == nil {
+			return nil, fmt.Errorf("line %d: missing key", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(*kv.Key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: key is not valid base64", line)
+		}
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: value is not valid base64", line)
+		}
+		entries = append(entries, importEntry{Source: string(key), Value: value})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// flattenTree turns a nested dump into entries whose Source is the '/'-joined
+// path from the root, starting with '/'. A "$value" member holds the value of
+// the directory key itself.
+func flattenTree(tree interface{}) ([]importEntry, error) {
+	if !isTreeObject(tree) {
+		return nil, fmt.Errorf("expected an object at the top level")
+	}
+	var entries []importEntry
+	if err := flattenNode("", tree, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func isTreeObject(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		return true
+	}
+	return false
+}
+
+func flattenNode(dir string, node interface{}, out *[]importEntry) error {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for name, child := range n {
+			if err := flattenMember(dir, name, child, out); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		// yaml.v2 decodes mappings with interface keys
+		for name, child := range n {
+			if err := flattenMember(dir, fmt.Sprint(name), child, out); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func flattenMember(dir, name string, v interface{}, out *[]importEntry) error {
+	path := dir + "/" + name
+	if name == treeValueMember {
+		path = dir
+	}
+	if isTreeObject(v) && !isBase64Value(v) {
+		if name == treeValueMember {
+			return fmt.Errorf("%q: %s must be a scalar", dir+"/"+name, treeValueMember)
+		}
+		return flattenNode(path, v, out)
+	}
+	value, err := importValue(v)
+	if err != nil {
+		return fmt.Errorf("%q: %w", dir+"/"+name, err)
+	}
+	*out = append(*out, importEntry{Source: path, Value: value})
+	return nil
+}
+
+// isBase64Value reports whether v is a {"$base64": ...} wrapped value rather
+// than a directory
+func isBase64Value(v interface{}) bool {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		_, ok := m[base64ValueMember]
+		return ok && len(m) == 1
+	case map[interface{}]interface{}:
+		_, ok := m[base64ValueMember]
+		return ok && len(m) == 1
+	}
+	return false
+}
+
+// importValue returns the raw bytes of a decoded value: a scalar, or a
+// base64 wrapped value as written by export
+func importValue(v interface{}) ([]byte, error) {
+	if !isBase64Value(v) {
+		s, err := scalarString(v)
+		return []byte(s), err
+	}
+	var encoded interface{}
+	switch m := v.(type) {
+	case map[string]interface{}:
+		encoded = m[base64ValueMember]
+	case map[interface{}]interface{}:
+		encoded = m[base64ValueMember]
+	}
+	s, ok := encoded.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a string", base64ValueMember)
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid base64", base64ValueMember)
+	}
+	return data, nil
+}
+
+// scalarString renders a decoded scalar as a value. Numbers and booleans keep
+// their literal form as far as the decoder allows; quote them to keep them verbatim.
+func scalarString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case json.Number:
+		return s.String(), nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	case int:
+		return strconv.Itoa(s), nil
+	case int64:
+		return strconv.FormatInt(s, 10), nil
+	case uint64:
+		return strconv.FormatUint(s, 10), nil
+	case float64:
+		return strconv.FormatFloat(s, 'g', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("null values are not supported")
+	case []interface{}:
+		return "", fmt.Errorf("arrays are not supported")
+	}
+	return "", fmt.Errorf("unsupported value of type %T", v)
+}
+
+// importKey places a dump key under prefix without doubling or dropping the '/' between them
+func importKey(prefix, rel string) string {
+	switch {
+	case rel == "":
+		return strings.TrimSuffix(prefix, "/")
+	case prefix == "":
+		return rel
+	case strings.HasSuffix(prefix, "/") && strings.HasPrefix(rel, "/"):
+		return prefix + rel[1:]
+	case !strings.HasSuffix(prefix, "/") && !strings.HasPrefix(rel, "/"):
+		return prefix + "/" + rel
+	}
+	return prefix + rel
+}
+
+// resolveImport sets the destination key of every entry and validates keys
+// and values. It returns one message per invalid entry.
+func resolveImport(entries []importEntry, req importReq) []string {
+	var problems []string
+	seen := make(map[string]string, len(entries))
+	for i := range entries {
+		e := &entries[i]
+		rel := e.Source
+		if req.StripPrefix != "" {
+			if !strings.HasPrefix(rel, req.StripPrefix) {
+				problems = append(problems, fmt.Sprintf("%q: key does not start with %q", e.Source, req.StripPrefix))
+				continue
+			}
+			rel = strings.TrimPrefix(rel, req.StripPrefix)
+		}
+		e.Key = importKey(req.Prefix, rel)
+		if err := validateKey(e.Key); err != nil {
+			problems = append(problems, fmt.Sprintf("%q: invalid key %q: %v", e.Source, e.Key, err))
+			continue
+		}
+		if err := validateValue(string(e.Value)); err != nil {
+			problems = append(problems, fmt.Sprintf("%q: %v", e.Source, err))
+			continue
+		}
+		if other, ok := seen[e.Key]; ok {
+			problems = append(problems, fmt.Sprintf("%q and %q both map to key %q", other, e.Source, e.Key))
+			continue
+		}
+		seen[e.Key] = e.Source
+	}
+	return problems
+}
+
+// fetchKVs returns the current revision of each key that exists
+func fetchKVs(r *http.Request, cli *clientv3.Client, keys []string) (map[string]*mvccpb.KeyValue, error) {
+	out := make(map[string]*mvccpb.KeyValue, len(keys))
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		ops := make([]clientv3.Op, n)
+		for i, k := range keys[:n] {
+			ops[i] = clientv3.OpGet(k)
+		}
+		resp, err := cli.Txn(r.Context()).Then(ops...).Commit()
+		if err != nil {
+			return nil, err
+		}
+		for _, res := range resp.Responses {
+			for _, kv := range res.GetResponseRange().Kvs {
+				out[string(kv.Key)] = kv
+			}
+		}
+		keys = keys[n:]
+	}
+	return out, nil
+}
+
+// importKeys writes the keys of an uploaded dump under a prefix. The dump is the
+// raw request body, in any format produced by export. Every key is planned
+// before anything is written; writes are applied in chunked transactions.
+func importKeys(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Read the dump before parsing the query: a form content type would
+		// otherwise have the body consumed as form fields
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			BadRequest(w, "failed to read request body: "+err.Error())
+			return
+		}
+		var req importReq
+		if err := httpx.ParseForm(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		switch req.Format {
+		case exportNested, exportFlat, exportYAML, exportJSONL:
+		default:
+			BadRequest(w, "format must be one of nested, flat, yaml, jsonl")
+			return
+		}
+		if req.Conflict != conflictOverwrite && req.Conflict != conflictSkip && req.Conflict != conflictFail {
+			BadRequest(w, "conflict must be one of overwrite, skip, fail")
+			return
+		}
+		if req.StripPrefix != "" && needsChildren(req.Format) {
+			BadRequest(w, "stripPrefix only applies to flat and jsonl dumps")
+			return
+		}
+
+		entries, err := parseImport(req.Format, data)
+		if err != nil {
+			BadRequest(w, fmt.Sprintf("invalid %s dump: %v", req.Format, err))
+			return
+		}
+		if len(entries) == 0 {
+			BadRequest(w, "dump contains no keys")
+			return
+		}
+		if problems := resolveImport(entries, req); len(problems) > 0 {
+			msg := fmt.Sprintf("%d invalid entries in dump", len(problems))
+			if len(problems) > maxImportErrors {
+				problems = append(problems[:maxImportErrors], fmt.Sprintf("... and %d more", len(problems)-maxImportErrors))
+			}
+			WriteError(w, http.StatusBadRequest, msg, strings.Join(problems, "\n"))
+			return
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+
+		targets := make([]writeTarget, len(entries))
+		keys := make([]string, len(entries))
+		for i, e := range entries {
+			targets[i] = keyTarget(e.Key)
+			keys[i] = e.Key
+		}
+		if !guardWrite(w, ctx, req.ConnID, targets...) {
+			return
+		}
+
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		current, err := fetchKVs(r, cli, keys)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+
+		// Plan every key before writing anything
+		resp := bulkResp{DryRun: req.DryRun, Total: len(entries), Results: make([]bulkKeyResult, len(entries))}
+		var pending []*mvccpb.KeyValue
+		var pendingIdx []int
+		conflicts := 0
+		for i, e := range entries {
+			var old []byte
+			cur, exists := current[e.Key]
+			if exists {
+				old = cur.Value
+			}
+			res := planWrite(e.Source, e.Key, old, exists, e.Value, req.Conflict)
+			if res.Action == bulkActionConflict {
+				conflicts++
+			}
+			if res.Action == bulkActionCreate || res.Action == bulkActionUpdate {
+				pending = append(pending, &mvccpb.KeyValue{Key: []byte(e.Key), Value: e.Value})
+				pendingIdx = append(pendingIdx, i)
+			}
+			resp.Results[i] = res
+		}
+		writes := make([]pendingValue, len(pending))
+		for i, kv := range pending {
+			writes[i] = pendingValue{string(kv.Key), kv.Value}
+		}
+		if !writeSchemaCheck(w, ctx, req.ConnID, writes...) {
+			return
+		}
+
+		if req.DryRun {
+			resp.Failed = conflicts
+			resp.Complete = conflicts == 0
+			httpx.OkJson(w, resp)
+			return
+		}
+		if conflicts > 0 {
+			resp.Failed = conflicts
+			httpx.WriteJson(w, http.StatusConflict, resp)
+			return
+		}
+
+		offset := 0
+		for _, chunk := range chunkKVs(pending, maxTxnOps) {
+			var cmps []clientv3.Cmp
+			var ops []clientv3.Op
+			for i, kv := range chunk {
+				res := resp.Results[pendingIdx[offset+i]]
+				if res.Action == bulkActionCreate && req.Conflict != conflictOverwrite {
+					cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(res.To), "=", 0))
+				}
+				ops = append(ops, clientv3.OpPut(res.To, string(kv.Value)))
+			}
+
+			tResp, err := cli.Txn(r.Context()).If(cmps...).Then(ops...).Commit()
+			status, msg := bulkStatusImported, ""
+			switch {
+			case err != nil:
+				status, msg = bulkStatusFailed, err.Error()
+			case !tResp.Succeeded:
+				status, msg = bulkStatusConflict, "destination created concurrently"
+			}
+			for i := range chunk {
+				resp.Results[pendingIdx[offset+i]].Status = status
+				resp.Results[pendingIdx[offset+i]].Error = msg
+			}
+			if status != bulkStatusImported {
+				resp.Failed = len(chunk)
+				auditBulk(ctx, r, opImport, req.ConnID, resp)
+				httpx.WriteJson(w, http.StatusConflict, resp)
+				return
+			}
+			resp.Succeeded += len(chunk)
+			resp.Revision = tResp.Header.Revision
+			offset += len(chunk)
+		}
+
+		resp.Complete = true
+		auditBulk(ctx, r, opImport, req.ConnID, resp)
+		httpx.OkJson(w, resp)
+	}
+}
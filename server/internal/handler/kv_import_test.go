@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// importedKeys resolves entries under prefix and returns destination key to value
+func importedKeys(t *testing.T, entries []importEntry, req importReq) map[string]string {
+	t.Helper()
+	if problems := resolveImport(entries, req); len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+	out := map[string]string{}
+	for _, e := range entries {
+		out[e.Key] = string(e.Value)
+	}
+	return out
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	keys := []string{"/app/a", "/app/a-b", "/app/a/x", "/app/a/y/z", "/app/c/"}
+	values := map[string]string{
+		"/app/a":     "dir value",
+		"/app/a-b":   "1",
+		"/app/a/x":   "quote \" and\nnewline",
+		"/app/a/y/z": "\x00\xffbinary",
+		"/app/c/":    "trailing slash",
+	}
+	withChildren := map[string]bool{"/app/a": true}
+
+	encode := func(format string) []byte {
+		var buf bytes.Buffer
+		var ew exportWriter
+		switch format {
+		case exportNested, exportYAML:
+			ew = newTreeEncoder(&buf, "/app/", format == exportYAML)
+		case exportFlat:
+			ew = &flatEncoder{w: &buf}
+		case exportJSONL:
+			ew = &jsonlEncoder{w: &buf, header: exportHeader{Prefix: "/app/"}}
+		}
+		for _, k := range keys {
+			if err := ew.writeKV(&mvccpb.KeyValue{Key: []byte(k), Value: []byte(values[k])}, withChildren[k]); err != nil {
+				t.Fatalf("writeKV failed: %v", err)
+			}
+		}
+		if err := ew.close(); err != nil {
+			t.Fatalf("close failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		format string
+		req    importReq
+	}{
+		{exportNested, importReq{Prefix: "/app/"}},
+		{exportYAML, importReq{Prefix: "/app/"}},
+		{exportFlat, importReq{}},
+		{exportJSONL, importReq{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			entries, err := parseImport(tt.format, encode(tt.format))
+			if err != nil {
+				t.Fatalf("parseImport failed: %v", err)
+			}
+			// Binary values survive every format
+			if got := importedKeys(t, entries, tt.req); !reflect.DeepEqual(got, values) {
+				t.Errorf("round trip mismatch:\n got %q\nwant %q", got, values)
+			}
+		})
+	}
+}
+
+func TestImportRelocate(t *testing.T) {
+	entries, err := parseImport(exportFlat, []byte(`{"/app/a": "1", "/app/b/c": "2"}`))
+	if err != nil {
+		t.Fatalf("parseImport failed: %v", err)
+	}
+	got := importedKeys(t, entries, importReq{Prefix: "/staging", StripPrefix: "/app"})
+	want := map[string]string{"/staging/a": "1", "/staging/b/c": "2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseImportScalars(t *testing.T) {
+	entries, err := parseImport(exportYAML, []byte("db:\n  port: 5432\n  ratio: 0.5\n  debug: true\n  host: \"db.local\"\n"))
+	if err != nil {
+		t.Fatalf("parseImport failed: %v", err)
+	}
+	got := importedKeys(t, entries, importReq{Prefix: "/cfg/"})
+	want := map[string]string{"/cfg/db/port": "5432", "/cfg/db/ratio": "0.5", "/cfg/db/debug": "true", "/cfg/db/host": "db.local"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{"nested not an object", exportNested, `["a"]`, "expected an object"},
+		{"nested null", exportNested, `{"a": null}`, "null values"},
+		{"nested array", exportNested, `{"a": {"b": [1]}}`, `"/a/b": arrays`},
+		{"object $value", exportNested, `{"a": {"$value": {}}}`, "must be a scalar"},
+		{"flat nested object", exportFlat, `{"/a": {"b": "c"}}`, `"/a"`},
+		{"flat syntax", exportFlat, `{"/a": `, "unexpected EOF"},
+		{"flat bad base64", exportFlat, `{"/a": {"$base64": "***"}}`, "$base64 is not valid base64"},
+		{"nested base64 not a string", exportNested, `{"a": {"$base64": 1}}`, "$base64 must be a string"},
+		{"jsonl missing key", exportJSONL, "{\"header\":{}}\n{\"value\":\"\"}\n", "line 2: missing key"},
+		{"jsonl bad base64", exportJSONL, `{"key":"***"}`, "line 1: key is not valid base64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseImport(tt.format, []byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseImport() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveImportProblems(t *testing.T) {
+	entries := []importEntry{
+		{Source: "/app/a", Value: []byte("1")},
+		{Source: "/other/b", Value: []byte("2")},
+		{Source: "/app/big", Value: make([]byte, maxValueSize+1)},
+		{Source: "a", Value: []byte("3")},
+	}
+	problems := resolveImport(entries, importReq{StripPrefix: "/app"})
+	if len(problems) != 3 {
+		t.Fatalf("expected 3 problems, got %d: %v", len(problems), problems)
+	}
+	for i, want := range []string{"does not start with", "exceeds maximum", "does not start with"} {
+		if !strings.Contains(problems[i], want) {
+			t.Errorf("problem %d = %q, want containing %q", i, problems[i], want)
+		}
+	}
+
+	dup := []importEntry{{Source: "/a/b", Value: nil}, {Source: "a/b", Value: nil}}
+	problems = resolveImport(dup, importReq{Prefix: "/x/"})
+	if len(problems) != 1 || !strings.Contains(problems[0], "both map to key") {
+		t.Errorf("expected a duplicate key problem, got %v", problems)
+	}
+}
+
+func TestImportKey(t *testing.T) {
+	tests := []struct {
+		prefix, rel, want string
+	}{
+		{"", "/app/a", "/app/a"},
+		{"/x/", "/a", "/x/a"},
+		{"/x", "/a", "/x/a"},
+		{"/x", "a", "/x/a"},
+		{"/x/", "a", "/x/a"},
+		{"/x/", "", "/x"},
+		{"/x/", "/a/", "/x/a/"},
+	}
+	for _, tt := range tests {
+		if got := importKey(tt.prefix, tt.rel); got != tt.want {
+			t.Errorf("importKey(%q, %q) = %q, want %q", tt.prefix, tt.rel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// maxListLimit caps the number of children returned per page
+const maxListLimit = 1000
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// listCursor is the continuation state of a paged listing. It is handed to
+// clients as an opaque base64 token.
+type listCursor struct {
+	// Key is the first etcd key of the next child
+	Key string `json:"k"`
+	// Skip holds directory prefixes already reported on earlier pages
+	// that the scan has not passed yet
+	Skip []string `json:"s,omitempty"`
+}
+
+func encodeCursor(c listCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token, prefix string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || !strings.HasPrefix(c.Key, prefix) {
+		return c, errInvalidCursor
+	}
+	return c, nil
+}
+
+// pagedChild is an immediate child of a prefix with the lease of its key
+type pagedChild struct {
+	item  keyItem
+	lease int64
+}
+
+// listChildrenPaged returns up to limit immediate children of prefix, reading
+// keys only. Directories are skipped over with a single range jump, so large
+// subtrees cost one round trip each. The returned cursor is empty on the last page.
+// rev > 0 lists the tree as of that revision.
+func listChildrenPaged(ctx context.Context, cli *clientv3.Client, prefix string, limit int, token string, rev int64) ([]pagedChild, string, error) {
+	start := prefix
+	var skip []string
+	if token != "" {
+		c, err := decodeCursor(token, prefix)
+		if err != nil {
+			return nil, "", err
+		}
+		start, skip = c.Key, c.Skip
+	}
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	var revOpts []clientv3.OpOption
+	if rev > 0 {
+		revOpts = append(revOpts, clientv3.WithRev(rev))
+	}
+
+	var out []pagedChild
+	index := map[string]int{}
+	next := ""
+	for next == "" {
+		opts := append([]clientv3.OpOption{clientv3.WithRange(end), clientv3.WithKeysOnly(), clientv3.WithLimit(int64(limit + 1))}, revOpts...)
+		resp, err := cli.Get(ctx, start, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, kv := range resp.Kvs {
+			k := string(kv.Key)
+			if k < start {
+				// Inside a directory we already jumped over
+				continue
+			}
+			if dir := matchSkip(skip, k); dir != "" {
+				start = clientv3.GetPrefixRangeEnd(dir)
+				continue
+			}
+			remain := strings.TrimPrefix(k, prefix)
+			seg, isDir := remain, false
+			if idx := strings.Index(remain, "/"); idx >= 0 {
+				seg, isDir = remain[:idx], true
+			}
+			if isDir {
+				start = clientv3.GetPrefixRangeEnd(prefix + seg + "/")
+			} else {
+				start = k + "\x00"
+			}
+			if i, ok := index[seg]; ok {
+				// A key that holds data and also has children
+				out[i].item.IsDir = out[i].item.IsDir || isDir
+				continue
+			}
+			if len(out) == limit {
+				next = k
+				break
+			}
+			index[seg] = len(out)
+			out = append(out, pagedChild{item: keyItem{Key: prefix + seg, IsDir: isDir}, lease: int64(kv.Lease)})
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+	}
+	if next == "" {
+		return out, "", nil
+	}
+
+	// Leaves whose own children sort after the next page start have not been
+	// seen yet; look them up now and carry them forward as skipped directories.
+	cursor := listCursor{Key: next}
+	for _, dir := range skip {
+		if dir >= next {
+			cursor.Skip = append(cursor.Skip, dir)
+		}
+	}
+	var peek []int
+	for i, c := range out {
+		if !c.item.IsDir && c.item.Key+"/" >= next {
+			peek = append(peek, i)
+		}
+	}
+	for len(peek) > 0 {
+		n := len(peek)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		ops := make([]clientv3.Op, n)
+		for j, i := range peek[:n] {
+			ops[j] = clientv3.OpGet(out[i].item.Key+"/", append([]clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithCountOnly()}, revOpts...)...)
+		}
+		resp, err := cli.Txn(ctx).Then(ops...).Commit()
+		if err != nil {
+			return nil, "", err
+		}
+		for j, i := range peek[:n] {
+			if resp.Responses[j].GetResponseRange().Count > 0 {
+				out[i].item.IsDir = true
+				cursor.Skip = append(cursor.Skip, out[i].item.Key+"/")
+			}
+		}
+		peek = peek[n:]
+	}
+	return out, encodeCursor(cursor), nil
+}
+
+// matchSkip returns the skipped directory prefix containing key, if any
+func matchSkip(skip []string, key string) string {
+	for _, dir := range skip {
+		if strings.HasPrefix(key, dir) {
+			return dir
+		}
+	}
+	return ""
+}
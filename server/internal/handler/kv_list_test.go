@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := listCursor{Key: "/registry/pods/default", Skip: []string{"/registry/pods/"}}
+
+	got, err := decodeCursor(encodeCursor(c), "/registry/")
+	if err != nil {
+		t.Fatalf("decodeCursor() unexpected error = %v", err)
+	}
+	if got.Key != c.Key || len(got.Skip) != 1 || got.Skip[0] != c.Skip[0] {
+		t.Errorf("decodeCursor() = %+v, want %+v", got, c)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		prefix string
+	}{
+		{"not base64", "%%%", "/"},
+		{"not json", "bm90LWpzb24", "/"},
+		{"other prefix", encodeCursor(listCursor{Key: "/other/key"}), "/registry/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.token, tt.prefix); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("decodeCursor() error = %v, want %v", err, errInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestMatchSkip(t *testing.T) {
+	skip := []string{"/app/a/", "/app/c/"}
+
+	if got := matchSkip(skip, "/app/a/x"); got != "/app/a/" {
+		t.Errorf("matchSkip() = %q, want %q", got, "/app/a/")
+	}
+	if got := matchSkip(skip, "/app/a-b"); got != "" {
+		t.Errorf("matchSkip() = %q, want no match", got)
+	}
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/svc"
+)
+
+type revisionAtReq struct {
+	ConnID string `form:"connId"`
+	// At is an RFC 3339 timestamp
+	At string `form:"at"`
+}
+
+// revisionAtResp names the audit entry a timestamp was resolved through
+type revisionAtResp struct {
+	Revision  int64     `json:"revision"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+}
+
+type restoreReq struct {
+	ConnID string `json:"connId"`
+	// Key is the subtree root: the key itself and every key under Key/
+	Key      string `json:"key"`
+	Revision int64  `json:"revision"`
+	DryRun   bool   `json:"dryRun,optional"`
+	// ExpectedReadRevision is the readRevision of the preview. The restore is
+	// refused if the subtree changed after it.
+	ExpectedReadRevision int64 `json:"expectedReadRevision,optional"`
+}
+
+// restoreResp previews, or reports, a subtree restore. Items carry full keys;
+// left is the current value and right the value at Revision.
+type restoreResp struct {
+	DryRun   bool   `json:"dryRun,omitempty"`
+	Key      string `json:"key"`
+	Revision int64  `json:"revision"`
+	// ReadRevision is the revision the current subtree was read at
+	ReadRevision int64      `json:"readRevision"`
+	Created      []diffItem `json:"created"`
+	Updated      []diffItem `json:"updated"`
+	Deleted      []diffItem `json:"deleted"`
+	Unchanged    int        `json:"unchanged"`
+	// CommitRevision is the revision of the restoring transaction
+	CommitRevision int64 `json:"commitRevision,omitempty"`
+}
+
+// writeRevisionError reports reads at a compacted or future revision. It returns
+// false for other errors, which the caller still has to report.
+func writeRevisionError(w http.ResponseWriter, err error, rev int64) bool {
+	switch {
+	case errors.Is(err, rpctypes.ErrCompacted):
+		WriteError(w, http.StatusGone, fmt.Sprintf("revision %d has been compacted", rev), err.Error())
+	case errors.Is(err, rpctypes.ErrFutureRev):
+		BadRequest(w, fmt.Sprintf("revision %d is newer than the current revision", rev))
+	default:
+		return false
+	}
+	return true
+}
+
+// revisionAt resolves a timestamp to the revision of the last audited change on
+// the connection at or before it. etcd keeps no wall-clock times, so changes
+// made outside the manager after that entry are not reflected.
+func revisionAt(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req revisionAtReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		at, err := time.Parse(time.RFC3339, req.At)
+		if err != nil {
+			BadRequest(w, "at must be an RFC 3339 timestamp")
+			return
+		}
+		if ctx.Audit == nil {
+			BadRequest(w, "resolving a time needs the audit log, which is disabled")
+			return
+		}
+
+		entries, err := ctx.Audit.Query(audit.Filter{
+			ConnID:       req.ConnID,
+			Until:        at.Add(time.Nanosecond),
+			Limit:        1,
+			WithRevision: true,
+		})
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		if len(entries) == 0 {
+			NotFound(w, "no audited change on this connection at or before "+req.At)
+			return
+		}
+		httpx.OkJson(w, revisionAtResp{
+			Revision:  entries[0].Revision,
+			Time:      entries[0].Time,
+			Operation: entries[0].Operation,
+		})
+	}
+}
+
+// planRestore compares the current subtree with the subtree at the restore revision
+func planRestore(current, past []*mvccpb.KeyValue, resp *restoreResp) {
+	now := make(map[string]*mvccpb.KeyValue, len(current))
+	for _, kv := range current {
+		now[string(kv.Key)] = kv
+	}
+	for _, kv := range past {
+		key := string(kv.Key)
+		cur, ok := now[key]
+		delete(now, key)
+		switch {
+		case !ok:
+			resp.Created = append(resp.Created, rightItem(key, kv))
+		case string(cur.Value) == string(kv.Value):
+			resp.Unchanged++
+		default:
+			resp.Updated = append(resp.Updated, changedItem(key, cur, kv))
+		}
+	}
+	// Keys created after the revision, in key order
+	for _, kv := range current {
+		if _, ok := now[string(kv.Key)]; ok {
+			resp.Deleted = append(resp.Deleted, leftItem(string(kv.Key), kv))
+		}
+	}
+}
+
+// subtreeChanged reports whether any key was created, modified or deleted
+// between two sorted reads of a subtree
+func subtreeChanged(before, after []*mvccpb.KeyValue) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if string(before[i].Key) != string(after[i].Key) || before[i].ModRevision != after[i].ModRevision {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreOps builds the writes of a planned restore, the keys they touch and
+// the values to validate. Items carry values in their response encoding, so
+// they are decoded back to the raw bytes read at the restore revision.
+func restoreOps(resp restoreResp) ([]clientv3.Op, []string, []pendingValue, error) {
+	var ops []clientv3.Op
+	var keys []string
+	var writes []pendingValue
+	for _, items := range [][]diffItem{resp.Created, resp.Updated} {
+		for _, it := range items {
+			value, err := decodeValue(it.RightValue, it.RightEncoding)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("value of %q: %w", it.Key, err)
+			}
+			ops = append(ops, clientv3.OpPut(it.Key, value))
+			keys = append(keys, it.Key)
+			writes = append(writes, pendingValue{it.Key, []byte(value)})
+		}
+	}
+	for _, it := range resp.Deleted {
+		ops = append(ops, clientv3.OpDelete(it.Key))
+		keys = append(keys, it.Key)
+	}
+	return ops, keys, writes, nil
+}
+
+// restoreSubtree puts a subtree back to its state at a past revision: keys are
+// recreated or reset to their old values and keys created since are deleted,
+// in a single transaction. Values are restored without their leases.
+func restoreSubtree(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req restoreReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := validateKey(req.Key); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		if req.Revision <= 0 {
+			BadRequest(w, "revision must be positive")
+			return
+		}
+		if !guardWrite(w, ctx, req.ConnID, subtreeTarget(req.Key)) {
+			return
+		}
+
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		base := subtreeBase(req.Key)
+		current, readRev, err := fetchSubtreeAt(r.Context(), cli, base, 0)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		past, _, err := fetchSubtreeAt(r.Context(), cli, base, req.Revision)
+		if err != nil {
+			if writeRevisionError(w, err, req.Revision) {
+				return
+			}
+			InternalError(w, err)
+			return
+		}
+
+		resp := restoreResp{
+			DryRun:       req.DryRun,
+			Key:          req.Key,
+			Revision:     req.Revision,
+			ReadRevision: readRev,
+			Created:      []diffItem{},
+			Updated:      []diffItem{},
+			Deleted:      []diffItem{},
+		}
+		bound := readRev
+		if req.ExpectedReadRevision > 0 && !req.DryRun {
+			if req.ExpectedReadRevision > readRev {
+				BadRequest(w, fmt.Sprintf("expectedReadRevision %d is newer than the current revision", req.ExpectedReadRevision))
+				return
+			}
+			// Changes after the preview, deletions included, would be undone unseen
+			previewed, _, err := fetchSubtreeAt(r.Context(), cli, base, req.ExpectedReadRevision)
+			if err != nil && !errors.Is(err, rpctypes.ErrCompacted) {
+				InternalError(w, err)
+				return
+			}
+			if err != nil || subtreeChanged(previewed, current) {
+				WriteError(w, http.StatusConflict, "subtree was modified after the preview, review the preview again", "")
+				return
+			}
+			bound = req.ExpectedReadRevision
+		}
+		planRestore(current, past, &resp)
+		if req.DryRun {
+			httpx.OkJson(w, resp)
+			return
+		}
+
+		changes := len(resp.Created) + len(resp.Updated) + len(resp.Deleted)
+		if changes == 0 {
+			httpx.OkJson(w, resp)
+			return
+		}
+		if changes > maxTxnOps {
+			BadRequest(w, fmt.Sprintf("restore needs %d changes, at most %d fit in one transaction", changes, maxTxnOps))
+			return
+		}
+
+		// Nothing in the subtree may have been written since the preview, or
+		// since it was read when no preview revision was given
+		cmps := []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(base+"/"), "<", bound+1).WithPrefix()}
+		if base != "" {
+			cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(base), "<", bound+1))
+		}
+		ops, keys, writes, err := restoreOps(resp)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		// Schemas bound since the revision apply to the restored values too
+		if !writeSchemaCheck(w, ctx, req.ConnID, writes...) {
+			return
+		}
+
+		tResp, err := cli.Txn(r.Context()).If(cmps...).Then(ops...).Commit()
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		if !tResp.Succeeded {
+			WriteError(w, http.StatusConflict, "subtree was modified during the restore, review the preview again", "")
+			return
+		}
+		resp.CommitRevision = tResp.Header.Revision
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opRestore,
+			Keys:      keys,
+			Count:     len(keys),
+			Target:    fmt.Sprintf("revision %d", req.Revision),
+			Revision:  resp.CommitRevision,
+		})
+		httpx.OkJson(w, resp)
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+func TestPlanRestore(t *testing.T) {
+	kv := func(key, value string, mod int64) *mvccpb.KeyValue {
+		return &mvccpb.KeyValue{Key: []byte(key), Value: []byte(value), ModRevision: mod}
+	}
+	current := []*mvccpb.KeyValue{kv("/app/a", "new", 9), kv("/app/b", "same", 3), kv("/app/d", "added", 8), kv("/app/e", "added", 10)}
+	past := []*mvccpb.KeyValue{kv("/app/a", "old", 4), kv("/app/b", "same", 3), kv("/app/c", "removed", 5)}
+
+	var resp restoreResp
+	planRestore(current, past, &resp)
+
+	if len(resp.Created) != 1 || resp.Created[0].Key != "/app/c" || resp.Created[0].RightValue != "removed" {
+		t.Errorf("unexpected created: %+v", resp.Created)
+	}
+	if len(resp.Updated) != 1 || resp.Updated[0].Key != "/app/a" || resp.Updated[0].LeftValue != "new" || resp.Updated[0].RightValue != "old" {
+		t.Errorf("unexpected updated: %+v", resp.Updated)
+	}
+	if resp.Updated[0].Diff == "" {
+		t.Error("expected a value diff for the updated key")
+	}
+	if len(resp.Deleted) != 2 || resp.Deleted[0].Key != "/app/d" || resp.Deleted[1].Key != "/app/e" {
+		t.Errorf("unexpected deleted: %+v", resp.Deleted)
+	}
+	if resp.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", resp.Unchanged)
+	}
+}
+
+func TestSubtreeChanged(t *testing.T) {
+	kv := func(key string, mod int64) *mvccpb.KeyValue {
+		return &mvccpb.KeyValue{Key: []byte(key), ModRevision: mod}
+	}
+	preview := []*mvccpb.KeyValue{kv("/app/a", 4), kv("/app/b", 6)}
+	tests := []struct {
+		name    string
+		current []*mvccpb.KeyValue
+		want    bool
+	}{
+		{"unchanged", []*mvccpb.KeyValue{kv("/app/a", 4), kv("/app/b", 6)}, false},
+		{"modified", []*mvccpb.KeyValue{kv("/app/a", 4), kv("/app/b", 9)}, true},
+		{"deleted", []*mvccpb.KeyValue{kv("/app/a", 4)}, true},
+		{"created", []*mvccpb.KeyValue{kv("/app/a", 4), kv("/app/b", 6), kv("/app/c", 9)}, true},
+		{"replaced", []*mvccpb.KeyValue{kv("/app/a", 4), kv("/app/c", 9)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtreeChanged(preview, tt.current); got != tt.want {
+				t.Errorf("subtreeChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestoreOpsBinaryValue(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0xfe, 'a'}
+	current := []*mvccpb.KeyValue{{Key: []byte("/app/a"), Value: []byte("text"), ModRevision: 9}, {Key: []byte("/app/d"), Value: []byte("x"), ModRevision: 8}}
+	past := []*mvccpb.KeyValue{{Key: []byte("/app/a"), Value: binary, ModRevision: 4}, {Key: []byte("/app/c"), Value: binary, ModRevision: 5}}
+
+	var resp restoreResp
+	planRestore(current, past, &resp)
+	ops, keys, writes, err := restoreOps(resp)
+	if err != nil {
+		t.Fatalf("restoreOps: %v", err)
+	}
+	if len(ops) != 3 || len(keys) != 3 {
+		t.Fatalf("got %d ops for keys %v, want 3", len(ops), keys)
+	}
+	// Only the puts are validated against schemas
+	if len(writes) != 2 || !bytes.Equal(writes[0].value, binary) {
+		t.Errorf("unexpected values to validate: %+v", writes)
+	}
+	for _, op := range ops[:2] {
+		if !op.IsPut() || !bytes.Equal(op.ValueBytes(), binary) {
+			t.Errorf("put of %s = %q, want the raw binary value", op.KeyBytes(), op.ValueBytes())
+		}
+	}
+	if !ops[2].IsDelete() || string(ops[2].KeyBytes()) != "/app/d" {
+		t.Errorf("expected a delete of /app/d, got %s", ops[2].KeyBytes())
+	}
+}
+
+func TestWriteRevisionError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		handled bool
+		code    int
+	}{
+		{"compacted", rpctypes.ErrCompacted, true, http.StatusGone},
+		{"future", rpctypes.ErrFutureRev, true, http.StatusBadRequest},
+		{"other", errors.New("boom"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if got := writeRevisionError(w, tt.err, 5); got != tt.handled {
+				t.Fatalf("writeRevisionError() = %v, want %v", got, tt.handled)
+			}
+			if tt.handled && w.Code != tt.code {
+				t.Errorf("status = %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
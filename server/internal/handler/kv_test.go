@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+func TestWriteEditConflict(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeEditConflict(w, "/a", 5, []*mvccpb.KeyValue{{Key: []byte("/a"), Value: []byte("theirs"), ModRevision: 7, Version: 3}})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", w.Code)
+	}
+	var resp editConflictResp
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !resp.Exists || resp.Value != "theirs" || resp.Encoding != encodingUTF8 || resp.ModRevision != 7 || resp.Version != 3 || resp.ExpectedModRevision != 5 {
+		t.Errorf("unexpected conflict response: %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	writeEditConflict(w, "/a", 5, nil)
+	resp = editConflictResp{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Exists || resp.Value != "" || resp.ModRevision != 0 {
+		t.Errorf("a deleted key must not report a value: %+v", resp)
+	}
+}
@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/svc"
+)
+
+// Compare targets of a transaction, named as in etcdctl txn
+const (
+	txnTargetValue   = "value"
+	txnTargetVersion = "version"
+	txnTargetCreate  = "create"
+	txnTargetMod     = "mod"
+	txnTargetLease   = "lease"
+)
+
+// Operations of a transaction branch
+const (
+	txnOpPut          = "put"
+	txnOpDelete       = "delete"
+	txnOpDeletePrefix = "delete-prefix"
+	txnOpGet          = "get"
+)
+
+// maxTxnGetKeys caps the keys returned by a single get
+const maxTxnGetKeys = exportPageSize
+
+type txnCompare struct {
+	Key string `json:"key"`
+	// Target is value, version, create, mod or lease
+	Target string `json:"target"`
+	// Result is =, !=, < or >
+	Result string `json:"result"`
+	// Value is compared for the value target; for lease it is a hex lease id, 0 for none
+	Value    string `json:"value,optional"`
+	Encoding string `json:"encoding,optional"`
+	// Number is compared for the version, create and mod targets
+	Number int64 `json:"number,optional"`
+	// Prefix applies the compare to every key starting with Key
+	Prefix bool `json:"prefix,optional"`
+}
+
+type txnOp struct {
+	// Type is put, delete, delete-prefix or get
+	Type     string `json:"type"`
+	Key      string `json:"key"`
+	Value    string `json:"value,optional"`
+	Encoding string `json:"encoding,optional"`
+	// Lease attaches a put to an existing lease (hex id)
+	Lease string `json:"lease,optional"`
+	// Prefix makes a get return every key starting with Key
+	Prefix bool `json:"prefix,optional"`
+}
+
+type txnReq struct {
+	ConnID  string       `json:"connId"`
+	Compare []txnCompare `json:"compare,optional"`
+	// Then runs when every compare holds, Else otherwise
+	Then []txnOp `json:"then,optional"`
+	Else []txnOp `json:"else,optional"`
+}
+
+type txnKV struct {
+	Key            string `json:"key"`
+	Value          string `json:"value"`
+	Encoding       string `json:"encoding"`
+	CreateRevision int64  `json:"createRevision"`
+	ModRevision    int64  `json:"modRevision"`
+	Version        int64  `json:"version"`
+	Lease          string `json:"lease,omitempty"` // hex lease id
+}
+
+// txnOpResult is the outcome of one operation of the branch that ran
+type txnOpResult struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+	// Prev is the value replaced by a put or removed by a delete
+	Prev *txnKV `json:"prev,omitempty"`
+	// Deleted counts the keys removed by delete and delete-prefix
+	Deleted int64 `json:"deleted,omitempty"`
+	// Kvs, Count and More are set for get; More means Kvs was cut at the limit
+	Kvs   []txnKV `json:"kvs,omitempty"`
+	Count int64   `json:"count,omitempty"`
+	More  bool    `json:"more,omitempty"`
+}
+
+type txnResp struct {
+	// Succeeded reports whether every compare held, so Then ran
+	Succeeded bool          `json:"succeeded"`
+	Revision  int64         `json:"revision"`
+	Results   []txnOpResult `json:"results"`
+}
+
+func newTxnKV(kv *mvccpb.KeyValue) txnKV {
+	out := txnKV{
+		Key:            string(kv.Key),
+		CreateRevision: kv.CreateRevision,
+		ModRevision:    kv.ModRevision,
+		Version:        kv.Version,
+		Lease:          formatLeaseID(kv.Lease),
+	}
+	out.Value, out.Encoding = encodeValue(kv.Value)
+	return out
+}
+
+// buildCompare converts a compare clause to its etcd form
+func buildCompare(c txnCompare) (clientv3.Cmp, error) {
+	if err := validateKey(c.Key); err != nil {
+		return clientv3.Cmp{}, err
+	}
+	switch c.Result {
+	case "=", "!=", "<", ">":
+	default:
+		return clientv3.Cmp{}, fmt.Errorf("unsupported result %q, expected =, !=, < or >", c.Result)
+	}
+
+	var cmp clientv3.Cmp
+	switch c.Target {
+	case txnTargetValue:
+		value, err := decodeValue(c.Value, c.Encoding)
+		if err != nil {
+			return clientv3.Cmp{}, err
+		}
+		cmp = clientv3.Compare(clientv3.Value(c.Key), c.Result, value)
+	case txnTargetVersion:
+		cmp = clientv3.Compare(clientv3.Version(c.Key), c.Result, c.Number)
+	case txnTargetCreate:
+		cmp = clientv3.Compare(clientv3.CreateRevision(c.Key), c.Result, c.Number)
+	case txnTargetMod:
+		cmp = clientv3.Compare(clientv3.ModRevision(c.Key), c.Result, c.Number)
+	case txnTargetLease:
+		var id clientv3.LeaseID
+		if c.Value != "" && c.Value != "0" {
+			var err error
+			if id, err = parseLeaseID(c.Value); err != nil {
+				return clientv3.Cmp{}, err
+			}
+		}
+		cmp = clientv3.Compare(clientv3.LeaseValue(c.Key), c.Result, id)
+	default:
+		return clientv3.Cmp{}, fmt.Errorf("unsupported target %q, expected value, version, create, mod or lease", c.Target)
+	}
+	if c.Prefix {
+		cmp = cmp.WithPrefix()
+	}
+	return cmp, nil
+}
+
+// buildOp converts a branch operation to its etcd form. Writes also return
+// the target to guard and, for puts, the value to validate.
+func buildOp(o txnOp) (clientv3.Op, *writeTarget, *pendingValue, error) {
+	if err := validateKey(o.Key); err != nil {
+		return clientv3.Op{}, nil, nil, err
+	}
+	switch o.Type {
+	case txnOpPut:
+		value, err := decodeValue(o.Value, o.Encoding)
+		if err != nil {
+			return clientv3.Op{}, nil, nil, err
+		}
+		if err := validateValue(value); err != nil {
+			return clientv3.Op{}, nil, nil, err
+		}
+		opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+		if o.Lease != "" {
+			id, err := parseLeaseID(o.Lease)
+			if err != nil {
+				return clientv3.Op{}, nil, nil, err
+			}
+			opts = append(opts, clientv3.WithLease(id))
+		}
+		t := keyTarget(o.Key)
+		return clientv3.OpPut(o.Key, value, opts...), &t, &pendingValue{o.Key, []byte(value)}, nil
+	case txnOpDelete:
+		t := keyTarget(o.Key)
+		return clientv3.OpDelete(o.Key, clientv3.WithPrevKV()), &t, nil, nil
+	case txnOpDeletePrefix:
+		t := prefixTarget(o.Key)
+		return clientv3.OpDelete(o.Key, clientv3.WithPrefix()), &t, nil, nil
+	case txnOpGet:
+		var opts []clientv3.OpOption
+		if o.Prefix {
+			opts = append(opts, clientv3.WithPrefix(), clientv3.WithLimit(maxTxnGetKeys))
+		}
+		return clientv3.OpGet(o.Key, opts...), nil, nil, nil
+	}
+	return clientv3.Op{}, nil, nil, fmt.Errorf("unsupported operation %q, expected put, delete, delete-prefix or get", o.Type)
+}
+
+// txnResult reports the response of one executed operation
+func txnResult(o txnOp, resp *etcdserverpb.ResponseOp) txnOpResult {
+	res := txnOpResult{Type: o.Type, Key: o.Key}
+	switch {
+	case resp.GetResponsePut() != nil:
+		if prev := resp.GetResponsePut().PrevKv; prev != nil {
+			kv := newTxnKV(prev)
+			res.Prev = &kv
+		}
+	case resp.GetResponseDeleteRange() != nil:
+		dr := resp.GetResponseDeleteRange()
+		res.Deleted = dr.Deleted
+		if o.Type == txnOpDelete && len(dr.PrevKvs) > 0 {
+			kv := newTxnKV(dr.PrevKvs[0])
+			res.Prev = &kv
+		}
+	case resp.GetResponseRange() != nil:
+		rr := resp.GetResponseRange()
+		res.Count, res.More = rr.Count, rr.More
+		res.Kvs = make([]txnKV, 0, len(rr.Kvs))
+		for _, kv := range rr.Kvs {
+			res.Kvs = append(res.Kvs, newTxnKV(kv))
+		}
+	}
+	return res
+}
+
+// runTxn applies compare clauses and then/else branches of puts, deletes and
+// gets in a single etcd transaction
+func runTxn(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req txnReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if len(req.Then) == 0 && len(req.Else) == 0 {
+			BadRequest(w, "then or else must contain at least one operation")
+			return
+		}
+		if len(req.Compare) > maxTxnOps || len(req.Then) > maxTxnOps || len(req.Else) > maxTxnOps {
+			BadRequest(w, fmt.Sprintf("compare, then and else are limited to %d entries each", maxTxnOps))
+			return
+		}
+
+		cmps := make([]clientv3.Cmp, len(req.Compare))
+		for i, c := range req.Compare {
+			cmp, err := buildCompare(c)
+			if err != nil {
+				BadRequest(w, fmt.Sprintf("compare %d: %v", i, err))
+				return
+			}
+			cmps[i] = cmp
+		}
+		var targets []writeTarget
+		var writes []pendingValue
+		branch := func(name string, list []txnOp) ([]clientv3.Op, bool) {
+			ops := make([]clientv3.Op, len(list))
+			for i, o := range list {
+				op, target, pv, err := buildOp(o)
+				if err != nil {
+					BadRequest(w, fmt.Sprintf("%s %d: %v", name, i, err))
+					return nil, false
+				}
+				ops[i] = op
+				if target != nil {
+					targets = append(targets, *target)
+				}
+				if pv != nil {
+					writes = append(writes, *pv)
+				}
+			}
+			return ops, true
+		}
+		thenOps, ok := branch("then", req.Then)
+		if !ok {
+			return
+		}
+		elseOps, ok := branch("else", req.Else)
+		if !ok {
+			return
+		}
+
+		// Both branches are checked, since either may run
+		if !writeSchemaCheck(w, ctx, req.ConnID, writes...) {
+			return
+		}
+		if len(targets) > 0 && !guardWrite(w, ctx, req.ConnID, targets...) {
+			return
+		}
+
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		tResp, err := cli.Txn(r.Context()).If(cmps...).Then(thenOps...).Else(elseOps...).Commit()
+		if err != nil {
+			switch {
+			case isLeaseNotFound(err):
+				BadRequest(w, "lease not found")
+			case errors.Is(err, rpctypes.ErrDuplicateKey):
+				BadRequest(w, "a key is written more than once in the same branch")
+			case errors.Is(err, rpctypes.ErrTooManyOps):
+				BadRequest(w, err.Error())
+			default:
+				InternalError(w, err)
+			}
+			return
+		}
+
+		ran := req.Else
+		if tResp.Succeeded {
+			ran = req.Then
+		}
+		resp := txnResp{Succeeded: tResp.Succeeded, Revision: tResp.Header.Revision, Results: make([]txnOpResult, len(ran))}
+		var written []string
+		var changed int64
+		for i, o := range ran {
+			resp.Results[i] = txnResult(o, tResp.Responses[i])
+			switch o.Type {
+			case txnOpPut:
+				written = append(written, o.Key)
+				changed++
+			case txnOpDelete, txnOpDeletePrefix:
+				written = append(written, o.Key)
+				changed += resp.Results[i].Deleted
+			}
+		}
+		if len(written) > 0 {
+			recordAudit(ctx, r, audit.Entry{
+				ConnID:    req.ConnID,
+				Operation: opTxn,
+				Keys:      written,
+				Count:     int(changed),
+				Revision:  tResp.Header.Revision,
+			})
+		}
+		httpx.OkJson(w, resp)
+	}
+}
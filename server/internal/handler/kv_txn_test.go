@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+func TestBuildCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmp    txnCompare
+		target etcdserverpb.Compare_CompareTarget
+		ok     bool
+	}{
+		{"value", txnCompare{Key: "/a", Target: "value", Result: "=", Value: "on"}, etcdserverpb.Compare_VALUE, true},
+		{"base64 value", txnCompare{Key: "/a", Target: "value", Result: "!=", Value: "AP8=", Encoding: "base64"}, etcdserverpb.Compare_VALUE, true},
+		{"version", txnCompare{Key: "/a", Target: "version", Result: ">", Number: 0}, etcdserverpb.Compare_VERSION, true},
+		{"create", txnCompare{Key: "/a", Target: "create", Result: "=", Number: 0}, etcdserverpb.Compare_CREATE, true},
+		{"mod", txnCompare{Key: "/a", Target: "mod", Result: "<", Number: 10}, etcdserverpb.Compare_MOD, true},
+		{"no lease", txnCompare{Key: "/a", Target: "lease", Result: "=", Value: "0"}, etcdserverpb.Compare_LEASE, true},
+		{"lease", txnCompare{Key: "/a", Target: "lease", Result: "=", Value: "694d7b3f1a2c"}, etcdserverpb.Compare_LEASE, true},
+		{"bad lease", txnCompare{Key: "/a", Target: "lease", Result: "=", Value: "xyz"}, 0, false},
+		{"bad result", txnCompare{Key: "/a", Target: "mod", Result: ">="}, 0, false},
+		{"bad target", txnCompare{Key: "/a", Target: "size", Result: "="}, 0, false},
+		{"bad key", txnCompare{Key: "a", Target: "mod", Result: "="}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp, err := buildCompare(tt.cmp)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cmp.Target != tt.target {
+				t.Errorf("target = %v, want %v", cmp.Target, tt.target)
+			}
+		})
+	}
+
+	cmp, err := buildCompare(txnCompare{Key: "/flags/", Target: "version", Result: ">", Prefix: true})
+	if err != nil || len(cmp.RangeEnd) == 0 {
+		t.Errorf("prefix compare should set a range end, got %q, %v", cmp.RangeEnd, err)
+	}
+}
+
+func TestBuildOp(t *testing.T) {
+	op, target, pv, err := buildOp(txnOp{Type: "put", Key: "/a", Value: "AP8=", Encoding: "base64"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !op.IsPut() || target == nil || target.Key != "/a" || pv == nil || string(pv.value) != "\x00\xff" {
+		t.Errorf("unexpected put: target %+v, value %+v", target, pv)
+	}
+
+	op, target, _, err = buildOp(txnOp{Type: "delete-prefix", Key: "/flags/"})
+	if err != nil || !op.IsDelete() || target == nil || !target.Prefix {
+		t.Errorf("unexpected delete-prefix: target %+v, err %v", target, err)
+	}
+
+	op, target, _, err = buildOp(txnOp{Type: "get", Key: "/flags/", Prefix: true})
+	if err != nil || !op.IsGet() || target != nil {
+		t.Errorf("a get must not be guarded as a write: target %+v, err %v", target, err)
+	}
+
+	bad := []txnOp{
+		{Type: "put", Key: "/a", Value: "!", Encoding: "base64"},
+		{Type: "put", Key: "/a", Lease: "zz"},
+		{Type: "watch", Key: "/a"},
+		{Type: "delete", Key: ""},
+	}
+	for _, o := range bad {
+		if _, _, _, err := buildOp(o); err == nil {
+			t.Errorf("expected buildOp(%+v) to fail", o)
+		}
+	}
+}
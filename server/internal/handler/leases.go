@@ -0,0 +1,355 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	// maxLeaseDetails caps the number of TimeToLive lookups done by a list request
+	maxLeaseDetails = 500
+	// unusedLeaseRevokeTimeout bounds the revoke of a lease a write did not use
+	unusedLeaseRevokeTimeout = 5 * time.Second
+)
+
+type listLeasesReq struct {
+	ConnID string `form:"connId"`
+	// Details fetches TTL and attached key count for each lease
+	Details bool `form:"details,optional"`
+}
+
+type leaseReq struct {
+	ConnID string `form:"connId"`
+	ID     string `form:"id"`
+}
+
+type grantLeaseReq struct {
+	ConnID string `json:"connId"`
+	TTL    int64  `json:"ttl"`
+}
+
+type leaseActionReq struct {
+	ConnID string `json:"connId"`
+	ID     string `json:"id"`
+	// Periodic starts a background keep-alive instead of a single refresh
+	Periodic bool `json:"periodic,optional"`
+}
+
+// leaseItem describes a lease. IDs are hex strings, as printed by etcdctl,
+// because they do not fit in a JavaScript number.
+type leaseItem struct {
+	ID         string   `json:"id"`
+	TTL        int64    `json:"ttl"`
+	GrantedTTL int64    `json:"grantedTTL"`
+	Keys       []string `json:"keys,omitempty"`
+	KeyCount   int      `json:"keyCount"`
+	KeepAlive  bool     `json:"keepAlive"`
+}
+
+// formatLeaseID renders a lease ID as a hex string
+func formatLeaseID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(id, 16)
+}
+
+// parseLeaseID parses a hex lease ID
+func parseLeaseID(s string) (clientv3.LeaseID, error) {
+	id, err := strconv.ParseInt(s, 16, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid lease id %q, expected a hex string", s)
+	}
+	return clientv3.LeaseID(id), nil
+}
+
+// revokeUnusedLease revokes a lease granted for a write that did not happen,
+// so it does not linger until its TTL runs out. A zero id is ignored.
+func revokeUnusedLease(cli *clientv3.Client, id clientv3.LeaseID) {
+	if id == 0 {
+		return
+	}
+	// The request context may already be cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), unusedLeaseRevokeTimeout)
+	defer cancel()
+	if _, err := cli.Revoke(ctx, id); err != nil && !isLeaseNotFound(err) {
+		logx.Errorf("failed to revoke unused lease %s: %v", formatLeaseID(int64(id)), err)
+	}
+}
+
+// isLeaseNotFound reports whether err is etcd's "requested lease not found"
+func isLeaseNotFound(err error) bool {
+	return errors.Is(err, rpctypes.ErrLeaseNotFound)
+}
+
+// leaseTargets returns the keys attached to a lease as write targets
+func leaseTargets(keys [][]byte) []writeTarget {
+	targets := make([]writeTarget, 0, len(keys))
+	for _, k := range keys {
+		targets = append(targets, keyTarget(string(k)))
+	}
+	return targets
+}
+
+func listLeases(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req listLeasesReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		resp, err := cli.Leases(r.Context())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		sort.Slice(resp.Leases, func(i, j int) bool { return resp.Leases[i].ID < resp.Leases[j].ID })
+
+		running := ctx.Manager.KeepAlives(req.ConnID)
+		out := make([]leaseItem, 0, len(resp.Leases))
+		for i, l := range resp.Leases {
+			item := leaseItem{ID: formatLeaseID(int64(l.ID)), KeepAlive: running[l.ID]}
+			if req.Details && i < maxLeaseDetails {
+				lt, err := cli.TimeToLive(r.Context(), l.ID, clientv3.WithAttachedKeys())
+				if err == nil {
+					item.TTL = lt.TTL
+					item.GrantedTTL = lt.GrantedTTL
+					item.KeyCount = len(lt.Keys)
+				}
+			}
+			out = append(out, item)
+		}
+		httpx.OkJson(w, map[string]interface{}{
+			"leases":    out,
+			"truncated": req.Details && len(resp.Leases) > maxLeaseDetails,
+		})
+	}
+}
+
+func getLease(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req leaseReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		id, err := parseLeaseID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		lt, err := cli.TimeToLive(r.Context(), id, clientv3.WithAttachedKeys())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		// An expired or revoked lease reports TTL -1
+		if lt.TTL < 0 {
+			NotFound(w, "lease not found or expired")
+			return
+		}
+		keys := make([]string, 0, len(lt.Keys))
+		for _, k := range lt.Keys {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		httpx.OkJson(w, leaseItem{
+			ID:         req.ID,
+			TTL:        lt.TTL,
+			GrantedTTL: lt.GrantedTTL,
+			Keys:       keys,
+			KeyCount:   len(keys),
+			KeepAlive:  ctx.Manager.KeepAlives(req.ConnID)[id],
+		})
+	}
+}
+
+func grantLease(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req grantLeaseReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if req.TTL <= 0 {
+			BadRequest(w, "ttl must be positive")
+			return
+		}
+		if !guardWrite(w, ctx, req.ConnID) {
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		lr, err := cli.Grant(r.Context(), req.TTL)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opLeaseGrant,
+			Target:    formatLeaseID(int64(lr.ID)),
+			Revision:  lr.ResponseHeader.Revision,
+		})
+		httpx.OkJson(w, leaseItem{ID: formatLeaseID(int64(lr.ID)), TTL: lr.TTL, GrantedTTL: lr.TTL})
+	}
+}
+
+func revokeLease(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req leaseActionReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		id, err := parseLeaseID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		// Revoking deletes the attached keys, so they are guarded like deletes
+		lt, err := cli.TimeToLive(r.Context(), id, clientv3.WithAttachedKeys())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		if !guardWrite(w, ctx, req.ConnID, leaseTargets(lt.Keys)...) {
+			return
+		}
+
+		ctx.Manager.StopKeepAlive(req.ConnID, id)
+		rResp, err := cli.Revoke(r.Context(), id)
+		if err != nil {
+			if isLeaseNotFound(err) {
+				NotFound(w, "lease not found")
+				return
+			}
+			InternalError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opLeaseRevoke,
+			Target:    req.ID,
+			Revision:  rResp.Header.Revision,
+		})
+		httpx.Ok(w)
+	}
+}
+
+// keepAliveLease refreshes a lease once, or starts a background keep-alive
+// that runs until stopped, the lease is revoked or the connection is closed.
+func keepAliveLease(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req leaseActionReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		id, err := parseLeaseID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		if !guardWrite(w, ctx, req.ConnID) {
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		resp, err := cli.KeepAliveOnce(r.Context(), id)
+		if err != nil {
+			if isLeaseNotFound(err) {
+				NotFound(w, "lease not found")
+				return
+			}
+			InternalError(w, err)
+			return
+		}
+		if req.Periodic {
+			if err := ctx.Manager.StartKeepAlive(req.ConnID, id); err != nil {
+				InternalError(w, err)
+				return
+			}
+		}
+		httpx.OkJson(w, leaseItem{ID: req.ID, TTL: resp.TTL, KeepAlive: req.Periodic})
+	}
+}
+
+func stopKeepAliveLease(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req leaseReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		id, err := parseLeaseID(req.ID)
+		if err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		// Without the keep-alive the lease expires and its keys are deleted
+		lt, err := cli.TimeToLive(r.Context(), id, clientv3.WithAttachedKeys())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		if !guardWrite(w, ctx, req.ConnID, leaseTargets(lt.Keys)...) {
+			return
+		}
+
+		if !ctx.Manager.StopKeepAlive(req.ConnID, id) {
+			NotFound(w, "no keep-alive running for lease")
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opLeaseKeepAliveStop,
+			Target:    req.ID,
+		})
+		httpx.Ok(w)
+	}
+}
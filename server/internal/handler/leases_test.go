@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"testing"
+
+	"etcd-manager/server/internal/model"
+)
+
+func TestLeaseIDRoundTrip(t *testing.T) {
+	// Larger than 2^53, so it would lose precision as a JSON number in the browser
+	const id int64 = 7587877262345678901
+
+	s := formatLeaseID(id)
+	got, err := parseLeaseID(s)
+	if err != nil {
+		t.Fatalf("parseLeaseID(%q) unexpected error = %v", s, err)
+	}
+	if int64(got) != id {
+		t.Errorf("parseLeaseID(%q) = %d, want %d", s, got, id)
+	}
+	if formatLeaseID(0) != "" {
+		t.Errorf("formatLeaseID(0) = %q, want empty", formatLeaseID(0))
+	}
+}
+
+func TestParseLeaseIDInvalid(t *testing.T) {
+	for _, s := range []string{"", "0", "-1", "xyz", "1234567890abcdef0"} {
+		if _, err := parseLeaseID(s); err == nil {
+			t.Errorf("parseLeaseID(%q) expected error but got nil", s)
+		}
+	}
+}
+
+func TestLeaseTargetsGuarded(t *testing.T) {
+	conn := model.Connection{Name: "prod", ProtectedPrefixes: []string{"/config/"}}
+	targets := leaseTargets([][]byte{[]byte("/app/a"), []byte("/config/db")})
+	if len(targets) != 2 || targets[1] != keyTarget("/config/db") {
+		t.Fatalf("unexpected targets: %+v", targets)
+	}
+	err := checkWritable(conn, targets...)
+	if blocked, ok := err.(*writeBlockedError); !ok || blocked.Prefix != "/config/" {
+		t.Errorf("expected the protected attached key to block the revoke, got %v", err)
+	}
+	if err := checkWritable(conn, leaseTargets(nil)...); err != nil {
+		t.Errorf("lease without keys should not be blocked, got %v", err)
+	}
+	conn.ReadOnly = true
+	if err := checkWritable(conn, leaseTargets(nil)...); err == nil {
+		t.Error("read-only connection should block lease changes")
+	}
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/auth"
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	maintenanceCompact    = "compact"
+	maintenanceDefragment = "defragment"
+	// defragTimeout bounds the defragmentation of a single endpoint
+	defragTimeout = 5 * time.Minute
+)
+
+// Compaction and defragmentation take two requests: without confirmToken the
+// request is a preview that returns the current state and a single-use token,
+// and only the token applies the previewed operation.
+
+type compactReq struct {
+	ConnID string `json:"connId"`
+	// Revision defaults to the current revision
+	Revision int64 `json:"revision,optional"`
+	// Physical waits until the compaction is applied to the backend
+	Physical     bool   `json:"physical,optional"`
+	ConfirmToken string `json:"confirmToken,optional"`
+}
+
+type defragmentReq struct {
+	ConnID string `json:"connId"`
+	// Endpoints defaults to every endpoint of the connection
+	Endpoints    []string `json:"endpoints,optional"`
+	ConfirmToken string   `json:"confirmToken,optional"`
+}
+
+// compactPlan and defragmentPlan are the parameters a confirmation token applies
+type compactPlan struct {
+	Revision int64
+	Physical bool
+}
+
+type defragmentPlan struct {
+	Endpoints []string
+}
+
+type maintenanceStats struct {
+	Revision  int64            `json:"revision"`
+	Endpoints []endpointStatus `json:"endpoints"`
+}
+
+type defragmentResult struct {
+	Endpoint   string `json:"endpoint"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+type maintenanceResp struct {
+	Operation string `json:"operation"`
+	Applied   bool   `json:"applied"`
+	// Set by the preview
+	ConfirmToken string     `json:"confirmToken,omitempty"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	// Revision and Physical describe a compaction, Endpoints a defragmentation
+	Revision  int64              `json:"revision,omitempty"`
+	Physical  bool               `json:"physical,omitempty"`
+	Endpoints []string           `json:"endpoints,omitempty"`
+	Before    maintenanceStats   `json:"before"`
+	After     *maintenanceStats  `json:"after,omitempty"`
+	Results   []defragmentResult `json:"results,omitempty"`
+}
+
+// maintenanceSubject binds a confirmation token to the caller, operation and connection
+func maintenanceSubject(r *http.Request, op, connID string) string {
+	var username string
+	if user, ok := auth.UserFrom(r.Context()); ok {
+		username = user.Username
+	}
+	return username + "\x00" + op + "\x00" + connID
+}
+
+// currentStats reads the current revision and the status of the given endpoints
+func currentStats(ctx context.Context, cli *clientv3.Client, endpoints []string) (maintenanceStats, error) {
+	resp, err := cli.Get(ctx, "\x00", clientv3.WithCountOnly())
+	if err != nil {
+		return maintenanceStats{}, err
+	}
+	stats := maintenanceStats{Revision: resp.Header.Revision}
+	for _, st := range endpointStatuses(ctx, cli) {
+		for _, ep := range endpoints {
+			if st.Endpoint == ep {
+				stats.Endpoints = append(stats.Endpoints, st)
+				break
+			}
+		}
+	}
+	return stats, nil
+}
+
+// resolveEndpoints checks requested against the connection's endpoints
+func resolveEndpoints(cli *clientv3.Client, requested []string) ([]string, error) {
+	all := cli.Endpoints()
+	if len(requested) == 0 {
+		return all, nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, ep := range requested {
+		found := false
+		for _, known := range all {
+			if ep == known {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("endpoint %q is not an endpoint of this connection", ep)
+		}
+		if !seen[ep] {
+			seen[ep] = true
+			out = append(out, ep)
+		}
+	}
+	return out, nil
+}
+
+func compactKeyspace(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req compactReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if req.Revision < 0 {
+			BadRequest(w, "revision must not be negative")
+			return
+		}
+		// Compaction discards history, which a read-only connection must keep
+		if !guardWrite(w, ctx, req.ConnID) {
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		subject := maintenanceSubject(r, maintenanceCompact, req.ConnID)
+
+		if req.ConfirmToken == "" {
+			before, err := currentStats(r.Context(), cli, cli.Endpoints())
+			if err != nil {
+				InternalError(w, err)
+				return
+			}
+			plan := compactPlan{Revision: req.Revision, Physical: req.Physical}
+			if plan.Revision == 0 {
+				plan.Revision = before.Revision
+			}
+			if plan.Revision > before.Revision {
+				BadRequest(w, fmt.Sprintf("revision %d is beyond the current revision %d", plan.Revision, before.Revision))
+				return
+			}
+			token, expires, err := ctx.Confirmations.Issue(subject, plan)
+			if err != nil {
+				InternalError(w, err)
+				return
+			}
+			httpx.OkJson(w, maintenanceResp{
+				Operation:    maintenanceCompact,
+				ConfirmToken: token,
+				ExpiresAt:    &expires,
+				Revision:     plan.Revision,
+				Physical:     plan.Physical,
+				Before:       before,
+			})
+			return
+		}
+
+		payload, err := ctx.Confirmations.Consume(req.ConfirmToken, subject)
+		if err != nil {
+			BadRequest(w, err.Error()+", request a new preview")
+			return
+		}
+		plan := payload.(compactPlan)
+		before, err := currentStats(r.Context(), cli, cli.Endpoints())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		var opts []clientv3.CompactOption
+		if plan.Physical {
+			opts = append(opts, clientv3.WithCompactPhysical())
+		}
+		cresp, err := cli.Compact(r.Context(), plan.Revision, opts...)
+		if err != nil {
+			if writeRevisionError(w, err, plan.Revision) {
+				return
+			}
+			InternalError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{
+			ConnID:    req.ConnID,
+			Operation: opCompact,
+			Target:    fmt.Sprintf("revision %d", plan.Revision),
+			Revision:  cresp.Header.Revision,
+		})
+
+		after, err := currentStats(r.Context(), cli, cli.Endpoints())
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		httpx.OkJson(w, maintenanceResp{
+			Operation: maintenanceCompact,
+			Applied:   true,
+			Revision:  plan.Revision,
+			Physical:  plan.Physical,
+			Before:    before,
+			After:     &after,
+		})
+	}
+}
+
+func defragmentEndpoints(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req defragmentReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		// Defragmenting blocks the member, which a read-only connection must not do
+		if !guardWrite(w, ctx, req.ConnID) {
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+		subject := maintenanceSubject(r, maintenanceDefragment, req.ConnID)
+
+		if req.ConfirmToken == "" {
+			endpoints, err := resolveEndpoints(cli, req.Endpoints)
+			if err != nil {
+				BadRequest(w, err.Error())
+				return
+			}
+			before, err := currentStats(r.Context(), cli, endpoints)
+			if err != nil {
+				InternalError(w, err)
+				return
+			}
+			token, expires, err := ctx.Confirmations.Issue(subject, defragmentPlan{Endpoints: endpoints})
+			if err != nil {
+				InternalError(w, err)
+				return
+			}
+			httpx.OkJson(w, maintenanceResp{
+				Operation:    maintenanceDefragment,
+				ConfirmToken: token,
+				ExpiresAt:    &expires,
+				Endpoints:    endpoints,
+				Before:       before,
+			})
+			return
+		}
+
+		payload, err := ctx.Confirmations.Consume(req.ConfirmToken, subject)
+		if err != nil {
+			BadRequest(w, err.Error()+", request a new preview")
+			return
+		}
+		plan := payload.(defragmentPlan)
+		before, err := currentStats(r.Context(), cli, plan.Endpoints)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+
+		// One endpoint at a time: a member is unavailable while it defragments
+		resp := maintenanceResp{Operation: maintenanceDefragment, Applied: true, Endpoints: plan.Endpoints, Before: before}
+		var done []string
+		for _, ep := range plan.Endpoints {
+			start := time.Now()
+			dctx, cancel := context.WithTimeout(r.Context(), defragTimeout)
+			_, err := cli.Defragment(dctx, ep)
+			cancel()
+			res := defragmentResult{Endpoint: ep, DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Error = err.Error()
+			} else {
+				done = append(done, ep)
+			}
+			resp.Results = append(resp.Results, res)
+		}
+		if len(done) > 0 {
+			recordAudit(ctx, r, audit.Entry{
+				ConnID:    req.ConnID,
+				Operation: opDefragment,
+				Target:    strings.Join(done, ","),
+			})
+		}
+
+		after, err := currentStats(r.Context(), cli, plan.Endpoints)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		resp.After = &after
+		httpx.OkJson(w, resp)
+	}
+}
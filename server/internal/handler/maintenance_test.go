@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestResolveEndpoints(t *testing.T) {
+	// The client dials lazily, so no etcd is needed
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{"127.0.0.1:1", "127.0.0.1:2"}})
+	if err != nil {
+		t.Fatalf("clientv3.New failed: %v", err)
+	}
+	defer cli.Close()
+
+	got, err := resolveEndpoints(cli, nil)
+	if err != nil || !reflect.DeepEqual(got, []string{"127.0.0.1:1", "127.0.0.1:2"}) {
+		t.Errorf("default endpoints = %v, %v", got, err)
+	}
+	got, err = resolveEndpoints(cli, []string{"127.0.0.1:2", "127.0.0.1:2"})
+	if err != nil || !reflect.DeepEqual(got, []string{"127.0.0.1:2"}) {
+		t.Errorf("requested endpoints = %v, %v", got, err)
+	}
+	if _, err := resolveEndpoints(cli, []string{"127.0.0.1:3"}); err == nil {
+		t.Error("expected an error for an unknown endpoint")
+	}
+}
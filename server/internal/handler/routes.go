@@ -5,6 +5,7 @@ import (
 
     "github.com/zeromicro/go-zero/rest"
 
+    "etcd-manager/server/internal/model"
     "etcd-manager/server/internal/svc"
 )
 
@@ -16,27 +17,92 @@ func RegisterHandlers(server *rest.Server, ctx *svc.ServiceContext) {
         Handler: HealthCheck(ctx),
     })
 
-    // Connections
-    server.AddRoute(rest.Route{Method: http.MethodGet, Path: "/api/connections", Handler: listConnections(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/connections", Handler: addConnection(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPut, Path: "/api/connections", Handler: updateConnection(ctx)})
-    // Use query param id to avoid path var parsing issues
-    server.AddRoute(rest.Route{Method: http.MethodDelete, Path: "/api/connections", Handler: deleteConnection(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/connections/connect", Handler: connect(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/connections/disconnect", Handler: disconnect(ctx)})
-
-    // KV
-    server.AddRoute(rest.Route{Method: http.MethodGet, Path: "/api/kv/list", Handler: listKeys(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodGet, Path: "/api/kv", Handler: getKey(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPut, Path: "/api/kv", Handler: putKey(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/kv", Handler: createKey(ctx)})
-    // Use query param for key to support keys containing '/'
-    server.AddRoute(rest.Route{Method: http.MethodDelete, Path: "/api/kv", Handler: deleteKey(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/kv/rename", Handler: renameKey(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/kv/copy", Handler: copyKey(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/kv/batch-delete", Handler: batchDeleteKeys(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodGet, Path: "/api/kv/history", Handler: getKeyHistory(ctx)})
-    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/kv/rollback", Handler: rollbackKey(ctx)})
+    // Auth (public)
+    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/auth/login", Handler: login(ctx)})
+    server.AddRoute(rest.Route{Method: http.MethodPost, Path: "/api/auth/logout", Handler: logout(ctx)})
+
+    // Viewer: read-only access
+    server.AddRoutes(rest.WithMiddleware(ctx.Auth.Require(model.RoleViewer),
+        rest.Route{Method: http.MethodGet, Path: "/api/auth/me", Handler: currentUser(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/auth/password", Handler: changePassword(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/connections", Handler: listConnections(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/list", Handler: listKeys(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/kv", Handler: getKey(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/history", Handler: getKeyHistory(ctx)},
+        // Resolves a timestamp to a revision through the audit log
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/revision", Handler: revisionAt(ctx)},
+        // Server-Sent Events stream of watch events
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/watch", Handler: watchKeys(ctx)},
+        // Streamed download of every key under a prefix
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/export", Handler: exportKeys(ctx)},
+        // Compares two prefixes; POST only because of the request body
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/diff", Handler: diffPrefixes(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/format", Handler: formatValue(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/kv/schema-report", Handler: schemaReport(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/schemas", Handler: listSchemaBindings(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/leases", Handler: listLeases(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/leases/detail", Handler: getLease(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/cluster", Handler: getCluster(ctx)},
+    ))
+
+    // Editor: key and lease changes, opening and closing connections
+    server.AddRoutes(rest.WithMiddleware(ctx.Auth.Require(model.RoleEditor),
+        rest.Route{Method: http.MethodPost, Path: "/api/connections/connect", Handler: connect(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/connections/disconnect", Handler: disconnect(ctx)},
+
+        rest.Route{Method: http.MethodPut, Path: "/api/kv", Handler: putKey(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv", Handler: createKey(ctx)},
+        // Use query param for key to support keys containing '/'
+        rest.Route{Method: http.MethodDelete, Path: "/api/kv", Handler: deleteKey(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/rename", Handler: renameKey(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/copy", Handler: copyKey(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/batch-delete", Handler: batchDeleteKeys(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/txn", Handler: runTxn(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/rollback", Handler: rollbackKey(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/restore", Handler: restoreSubtree(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/diff/sync", Handler: syncPrefixes(ctx)},
+
+        rest.Route{Method: http.MethodPost, Path: "/api/leases", Handler: grantLease(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/leases/revoke", Handler: revokeLease(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/leases/keepalive", Handler: keepAliveLease(ctx)},
+        rest.Route{Method: http.MethodDelete, Path: "/api/leases/keepalive", Handler: stopKeepAliveLease(ctx)},
+    ))
+
+    // Import takes the dump as the request body, which may exceed the default size limit
+    server.AddRoutes(rest.WithMiddleware(ctx.Auth.Require(model.RoleEditor),
+        rest.Route{Method: http.MethodPost, Path: "/api/kv/import", Handler: importKeys(ctx)},
+    ), rest.WithMaxBytes(maxImportSize))
+
+    // Admin: connection settings, cluster membership, maintenance and backups, schemas, users and the audit log
+    server.AddRoutes(rest.WithMiddleware(ctx.Auth.Require(model.RoleAdmin),
+        rest.Route{Method: http.MethodPost, Path: "/api/connections", Handler: addConnection(ctx)},
+        rest.Route{Method: http.MethodPut, Path: "/api/connections", Handler: updateConnection(ctx)},
+        // Use query param id to avoid path var parsing issues
+        rest.Route{Method: http.MethodDelete, Path: "/api/connections", Handler: deleteConnection(ctx)},
+
+        rest.Route{Method: http.MethodPost, Path: "/api/cluster/members", Handler: addMember(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/cluster/members/remove", Handler: removeMember(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/cluster/members/promote", Handler: promoteMember(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/cluster/compact", Handler: compactKeyspace(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/cluster/defragment", Handler: defragmentEndpoints(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/cluster/snapshot", Handler: downloadSnapshot(ctx)},
+        rest.Route{Method: http.MethodGet, Path: "/api/backups", Handler: listBackups(ctx)},
+
+        rest.Route{Method: http.MethodPost, Path: "/api/schemas", Handler: addSchemaBinding(ctx)},
+        rest.Route{Method: http.MethodPut, Path: "/api/schemas", Handler: updateSchemaBinding(ctx)},
+        rest.Route{Method: http.MethodDelete, Path: "/api/schemas", Handler: deleteSchemaBinding(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/users", Handler: listUsers(ctx)},
+        rest.Route{Method: http.MethodPost, Path: "/api/users", Handler: addUser(ctx)},
+        rest.Route{Method: http.MethodPut, Path: "/api/users", Handler: updateUser(ctx)},
+        rest.Route{Method: http.MethodDelete, Path: "/api/users", Handler: deleteUser(ctx)},
+
+        rest.Route{Method: http.MethodGet, Path: "/api/audit", Handler: queryAudit(ctx)},
+    ))
 
     // Static files (frontend) - Must be last to act as catch-all
     server.AddRoute(rest.Route{
@@ -44,4 +110,4 @@ func RegisterHandlers(server *rest.Server, ctx *svc.ServiceContext) {
         Path:    "/",
         Handler: SPAHandler("static"),
     })
-}
\ No newline at end of file
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"gopkg.in/yaml.v2"
+
+	"etcd-manager/server/internal/audit"
+	"etcd-manager/server/internal/schema"
+	"etcd-manager/server/internal/svc"
+)
+
+const (
+	// maxSchemaViolations caps the violations returned for one write
+	maxSchemaViolations = 100
+	// maxReportKeys caps the invalid keys listed by a prefix report
+	maxReportKeys = 1000
+)
+
+type schemaBindingReq struct {
+	Pattern     string `json:"pattern"`
+	ConnID      string `json:"connId,optional"`
+	Description string `json:"description,optional"`
+	// Schema is the JSON Schema document as text
+	Schema string `json:"schema"`
+}
+
+type updateSchemaBindingReq struct {
+	ID string `json:"id"`
+	schemaBindingReq
+}
+
+type deleteSchemaBindingReq struct {
+	ID string `form:"id"`
+}
+
+type schemaReportReq struct {
+	ConnID string `form:"connId"`
+	Prefix string `form:"prefix,optional"`
+}
+
+// keyViolation is a schema violation of the value of Key
+type keyViolation struct {
+	Key string `json:"key"`
+	schema.Violation
+}
+
+type schemaReportItem struct {
+	Key        string             `json:"key"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+type schemaReportResp struct {
+	Prefix   string `json:"prefix"`
+	Revision int64  `json:"revision"`
+	// Checked counts keys covered by a schema, Unbound the others
+	Checked   int                `json:"checked"`
+	Valid     int                `json:"valid"`
+	Unbound   int                `json:"unbound"`
+	Invalid   []schemaReportItem `json:"invalid"`
+	Truncated bool               `json:"truncated,omitempty"`
+}
+
+// pendingValue is a value about to be written to key
+type pendingValue struct {
+	key   string
+	value []byte
+}
+
+// decodeDocument decodes a value for schema validation. JSON is tried first,
+// then YAML or TOML when detected; anything else validates as a string.
+func decodeDocument(value []byte) interface{} {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err == nil && !dec.More() {
+		return doc
+	}
+
+	var parsed interface{}
+	switch detectFormat(string(value)) {
+	case formatYAML:
+		if yaml.Unmarshal(value, &parsed) != nil {
+			return string(value)
+		}
+		parsed = stringKeys(parsed)
+	case formatTOML:
+		var m map[string]interface{}
+		if toml.Unmarshal(value, &m) != nil {
+			return string(value)
+		}
+		parsed = m
+	default:
+		return string(value)
+	}
+	// Round trip through JSON so numbers and dates take their JSON form
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		return string(value)
+	}
+	dec = json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return string(value)
+	}
+	return doc
+}
+
+// stringKeys converts the interface-keyed mappings of yaml.v2 to JSON objects
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			m[fmt.Sprint(k)] = stringKeys(child)
+		}
+		return m
+	case []interface{}:
+		for i := range t {
+			t[i] = stringKeys(t[i])
+		}
+	}
+	return v
+}
+
+// schemaViolations validates values about to be written on connection connID
+// against the schemas bound to their keys
+func schemaViolations(ctx *svc.ServiceContext, connID string, writes ...pendingValue) []keyViolation {
+	var out []keyViolation
+	for _, pv := range writes {
+		if !ctx.Schemas.Applies(connID, pv.key) {
+			continue
+		}
+		for _, v := range ctx.Schemas.Validate(connID, pv.key, decodeDocument(pv.value)) {
+			out = append(out, keyViolation{Key: pv.key, Violation: v})
+		}
+	}
+	return out
+}
+
+// writeSchemaCheck writes a 400 listing the schema violations of writes in
+// Details. It returns false when any value must not be written.
+func writeSchemaCheck(w http.ResponseWriter, ctx *svc.ServiceContext, connID string, writes ...pendingValue) bool {
+	violations := schemaViolations(ctx, connID, writes...)
+	if len(violations) == 0 {
+		return true
+	}
+	keys := map[string]bool{}
+	for _, v := range violations {
+		keys[v.Key] = true
+	}
+	msg := fmt.Sprintf("%d values do not match their schemas", len(keys))
+	if len(keys) == 1 {
+		v := violations[0]
+		msg = fmt.Sprintf("value of %q does not match the schema of %s: %s", v.Key, v.Pattern, v.Message)
+		if v.Path != "" {
+			msg = fmt.Sprintf("value of %q does not match the schema of %s at %s: %s", v.Key, v.Pattern, v.Path, v.Message)
+		}
+	}
+	if len(violations) > maxSchemaViolations {
+		violations = violations[:maxSchemaViolations]
+	}
+	httpx.WriteJson(w, http.StatusBadRequest, ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: msg,
+		Details: violations,
+	})
+	return false
+}
+
+// writeSchemaStoreError maps schema store errors to responses
+func writeSchemaStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, schema.ErrNotFound):
+		NotFound(w, err.Error())
+	case errors.Is(err, schema.ErrInvalid):
+		BadRequest(w, err.Error())
+	default:
+		InternalError(w, err)
+	}
+}
+
+func listSchemaBindings(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		httpx.OkJson(w, map[string]interface{}{"bindings": ctx.Schemas.List()})
+	}
+}
+
+func addSchemaBinding(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req schemaBindingReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		b, err := ctx.Schemas.Add(schema.Binding{
+			Pattern:     req.Pattern,
+			ConnID:      req.ConnID,
+			Description: req.Description,
+			Schema:      json.RawMessage(req.Schema),
+		})
+		if err != nil {
+			writeSchemaStoreError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{ConnID: b.ConnID, Operation: opSchemaAdd, Target: b.Pattern})
+		httpx.OkJson(w, b)
+	}
+}
+
+func updateSchemaBinding(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req updateSchemaBindingReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		b, err := ctx.Schemas.Update(schema.Binding{
+			ID:          req.ID,
+			Pattern:     req.Pattern,
+			ConnID:      req.ConnID,
+			Description: req.Description,
+			Schema:      json.RawMessage(req.Schema),
+		})
+		if err != nil {
+			writeSchemaStoreError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{ConnID: b.ConnID, Operation: opSchemaUpdate, Target: b.Pattern})
+		httpx.OkJson(w, b)
+	}
+}
+
+func deleteSchemaBinding(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req deleteSchemaBindingReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := ctx.Schemas.Remove(req.ID); err != nil {
+			writeSchemaStoreError(w, err)
+			return
+		}
+		recordAudit(ctx, r, audit.Entry{Operation: opSchemaDelete, Target: req.ID})
+		httpx.Ok(w)
+	}
+}
+
+// schemaReport validates every key under a prefix, read at a single revision,
+// against the schemas bound to it
+func schemaReport(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req schemaReportReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		rr, err := newRangeReader(r.Context(), cli, req.Prefix)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		resp := schemaReportResp{Prefix: req.Prefix, Revision: rr.rev, Invalid: []schemaReportItem{}}
+		for kv := rr.peek(); kv != nil; kv = rr.peek() {
+			key := string(kv.Key)
+			if !ctx.Schemas.Applies(req.ConnID, key) {
+				resp.Unbound++
+			} else {
+				resp.Checked++
+				violations := ctx.Schemas.Validate(req.ConnID, key, decodeDocument(kv.Value))
+				switch {
+				case len(violations) == 0:
+					resp.Valid++
+				case len(resp.Invalid) < maxReportKeys:
+					resp.Invalid = append(resp.Invalid, schemaReportItem{Key: key, Violations: violations})
+				default:
+					resp.Truncated = true
+				}
+			}
+			if err := rr.next(r.Context()); err != nil {
+				InternalError(w, err)
+				return
+			}
+		}
+		httpx.OkJson(w, resp)
+	}
+}
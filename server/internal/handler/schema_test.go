@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"etcd-manager/server/internal/schema"
+	"etcd-manager/server/internal/svc"
+)
+
+func TestDecodeDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  interface{}
+	}{
+		{"json", `{"port": 80}`, map[string]interface{}{"port": json.Number("80")}},
+		{"json string", `"a"`, "a"},
+		{"yaml", "port: 80\nhosts:\n  - a\n", map[string]interface{}{"port": json.Number("80"), "hosts": []interface{}{"a"}}},
+		{"toml", "[server]\nport = 80\n", map[string]interface{}{"server": map[string]interface{}{"port": json.Number("80")}}},
+		{"plain", "hello world", "hello world"},
+		{"two json values", `1 2`, "1 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeDocument([]byte(tt.value)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeDocument(%q) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSchemaCheck(t *testing.T) {
+	store, err := schema.NewStore(filepath.Join(t.TempDir(), "schemas.json"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	_, err = store.Add(schema.Binding{
+		Pattern: "/services/*/config",
+		Schema:  json.RawMessage(`{"type": "object", "properties": {"port": {"type": "integer"}}}`),
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	ctx := &svc.ServiceContext{Schemas: store}
+
+	w := httptest.NewRecorder()
+	if !writeSchemaCheck(w, ctx, "c1",
+		pendingValue{"/services/api/config", []byte("port: 80")},
+		pendingValue{"/other", []byte("anything")},
+	) {
+		t.Fatalf("valid writes rejected: %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if writeSchemaCheck(w, ctx, "c1",
+		pendingValue{"/services/api/config", []byte(`{"port": "80"}`)},
+		pendingValue{"/services/web/config", []byte(`[]`)},
+	) {
+		t.Fatal("invalid writes accepted")
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var resp struct {
+		Details []keyViolation `json:"details"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Details) != 2 || resp.Details[0].Key != "/services/api/config" || resp.Details[0].Path != "/port" {
+		t.Errorf("unexpected details: %+v", resp.Details)
+	}
+
+	// Without a store nothing is validated
+	if !writeSchemaCheck(httptest.NewRecorder(), &svc.ServiceContext{}, "c1", pendingValue{"/services/api/config", []byte("[]")}) {
+		t.Error("writes rejected without schemas")
+	}
+}
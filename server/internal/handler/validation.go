@@ -1,16 +1,22 @@
 package handler
 
 import (
+	"encoding/pem"
 	"fmt"
 	"net/url"
 	"strings"
 	"unicode/utf8"
+
+	"etcd-manager/server/internal/etcd"
+	"etcd-manager/server/internal/model"
 )
 
 const (
 	maxKeyLength     = 1024      // Maximum key length in characters
 	maxValueSize     = 1048576   // Maximum value size: 1MB in bytes
 	maxConnectionName = 50        // Maximum connection name length
+	maxUsername       = 32        // Maximum UI username length
+	maxPasswordLength = 72        // bcrypt ignores bytes beyond 72
 )
 
 // validateEndpoint validates that the endpoint URL is properly formatted
@@ -93,6 +99,107 @@ func validateConnectionName(name string) error {
 	return nil
 }
 
+// validateUsername validates a UI username
+// Usernames follow the same character rules as connection names
+func validateUsername(name string) error {
+	if name == "" {
+		return fmt.Errorf("username cannot be empty")
+	}
+	if utf8.RuneCountInString(name) > maxUsername {
+		return fmt.Errorf("username exceeds maximum length of %d characters", maxUsername)
+	}
+	for _, r := range name {
+		if !isAlphanumericOrAllowed(r) {
+			return fmt.Errorf("username contains invalid character '%c', only alphanumeric, '-', and '_' allowed", r)
+		}
+	}
+	return nil
+}
+
+// validatePassword validates the length of a UI user password
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Errorf("password exceeds maximum length of %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
+// validateProtectedPrefixes validates the protected key prefixes of a connection
+func validateProtectedPrefixes(prefixes []string) error {
+	for _, p := range prefixes {
+		if p == "" {
+			return fmt.Errorf("protected prefix cannot be empty, use readOnly to protect every key")
+		}
+		if err := validateKey(p); err != nil {
+			return fmt.Errorf("invalid protected prefix %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// validateTLS validates TLS settings of a connection
+// Inline material must be PEM-encoded and a client certificate requires a matching key
+func validateTLS(t *model.TLSConfig) error {
+	if t == nil {
+		return nil
+	}
+
+	if t.CACert != "" && t.CACertFile != "" {
+		return fmt.Errorf("caCert and caCertFile are mutually exclusive")
+	}
+	if t.ClientCert != "" && t.ClientCertFile != "" {
+		return fmt.Errorf("clientCert and clientCertFile are mutually exclusive")
+	}
+	if t.ClientKey != "" && t.ClientKeyFile != "" {
+		return fmt.Errorf("clientKey and clientKeyFile are mutually exclusive")
+	}
+
+	files := []struct {
+		name string
+		path string
+	}{
+		{"caCertFile", t.CACertFile},
+		{"clientCertFile", t.ClientCertFile},
+		{"clientKeyFile", t.ClientKeyFile},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if err := etcd.ValidateCertFile(f.path); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+
+	hasCert := t.ClientCert != "" || t.ClientCertFile != ""
+	hasKey := t.ClientKey != "" || t.ClientKeyFile != ""
+	if hasCert != hasKey {
+		return fmt.Errorf("client certificate and client key must be provided together")
+	}
+
+	inline := []struct {
+		name    string
+		content string
+	}{
+		{"caCert", t.CACert},
+		{"clientCert", t.ClientCert},
+		{"clientKey", t.ClientKey},
+	}
+	for _, f := range inline {
+		if f.content == "" {
+			continue
+		}
+		if block, _ := pem.Decode([]byte(f.content)); block == nil {
+			return fmt.Errorf("%s is not valid PEM data", f.name)
+		}
+	}
+
+	return nil
+}
+
 // isAlphanumericOrAllowed checks if a rune is alphanumeric, hyphen, or underscore
 func isAlphanumericOrAllowed(r rune) bool {
 	return (r >= 'a' && r <= 'z') ||
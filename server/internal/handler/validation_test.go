@@ -3,6 +3,8 @@ package handler
 import (
 	"strings"
 	"testing"
+
+	"etcd-manager/server/internal/model"
 )
 
 func TestValidateEndpoint(t *testing.T) {
@@ -218,6 +220,144 @@ func TestValidateValue(t *testing.T) {
 	}
 }
 
+func TestValidateTLS(t *testing.T) {
+	const pemBlock = "-----BEGIN CERTIFICATE-----\nYWJj\n-----END CERTIFICATE-----\n"
+
+	tests := []struct {
+		name      string
+		tls       *model.TLSConfig
+		wantError bool
+		errorMsg  string
+	}{
+		// Valid cases
+		{
+			name:      "nil config",
+			tls:       nil,
+			wantError: false,
+		},
+		{
+			name:      "inline CA only",
+			tls:       &model.TLSConfig{CACert: pemBlock},
+			wantError: false,
+		},
+		{
+			name:      "file references",
+			tls:       &model.TLSConfig{CACertFile: "ca.pem", ClientCertFile: "prod/client.pem", ClientKeyFile: "prod/client-key.pem"},
+			wantError: false,
+		},
+		{
+			name:      "server name only",
+			tls:       &model.TLSConfig{ServerName: "etcd.internal"},
+			wantError: false,
+		},
+		// Invalid cases
+		{
+			name:      "inline and file CA",
+			tls:       &model.TLSConfig{CACert: pemBlock, CACertFile: "ca.pem"},
+			wantError: true,
+			errorMsg:  "mutually exclusive",
+		},
+		{
+			name:      "absolute file reference",
+			tls:       &model.TLSConfig{CACertFile: "/etc/etcd/ca.pem"},
+			wantError: true,
+			errorMsg:  "caCertFile",
+		},
+		{
+			name:      "file reference outside the certificate directory",
+			tls:       &model.TLSConfig{ClientCertFile: "client.pem", ClientKeyFile: "../connections.json"},
+			wantError: true,
+			errorMsg:  "clientKeyFile",
+		},
+		{
+			name:      "cert without key",
+			tls:       &model.TLSConfig{ClientCert: pemBlock},
+			wantError: true,
+			errorMsg:  "must be provided together",
+		},
+		{
+			name:      "invalid PEM",
+			tls:       &model.TLSConfig{CACert: "not a certificate"},
+			wantError: true,
+			errorMsg:  "caCert is not valid PEM data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTLS(tt.tls)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("validateTLS() expected error but got nil")
+					return
+				}
+				if tt.errorMsg != "" && !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("validateTLS() error = %v, want error containing %v", err, tt.errorMsg)
+				}
+			} else {
+				if err != nil {
+					t.Errorf("validateTLS() unexpected error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		wantError bool
+		errorMsg  string
+	}{
+		{"simple", "admin", false, ""},
+		{"with hyphen and underscore", "ops-team_1", false, ""},
+		{"empty", "", true, "cannot be empty"},
+		{"too long", strings.Repeat("a", 33), true, "exceeds maximum length"},
+		{"space", "john doe", true, "invalid character"},
+		{"at sign", "john@example", true, "invalid character"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUsername(tt.username)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("validateUsername() expected error but got nil")
+					return
+				}
+				if !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("validateUsername() error = %v, want error containing %v", err, tt.errorMsg)
+				}
+			} else if err != nil {
+				t.Errorf("validateUsername() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		wantError bool
+	}{
+		{"minimum length", "12345678", false},
+		{"maximum length", strings.Repeat("x", 72), false},
+		{"too short", "1234567", true},
+		{"empty", "", true},
+		{"too long", strings.Repeat("x", 73), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePassword(tt.password); (err != nil) != tt.wantError {
+				t.Errorf("validatePassword() error = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
+
 func TestValidateConnectionName(t *testing.T) {
 	tests := []struct {
 		name      string
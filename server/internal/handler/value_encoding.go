@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Encodings of values in JSON requests and responses. JSON strings cannot
+// carry arbitrary bytes, so binary values such as protobuf travel as base64.
+const (
+	encodingUTF8   = "utf8"
+	encodingBase64 = "base64"
+)
+
+// encodeValue returns value as a JSON-safe string with its encoding: valid
+// UTF-8 is returned as is, anything else as base64
+func encodeValue(value []byte) (string, string) {
+	if utf8.Valid(value) {
+		return string(value), encodingUTF8
+	}
+	return base64.StdEncoding.EncodeToString(value), encodingBase64
+}
+
+// decodeValue returns the raw bytes of a value sent with encoding, which
+// defaults to utf8
+func decodeValue(value, encoding string) (string, error) {
+	switch encoding {
+	case "", encodingUTF8:
+		return value, nil
+	case encodingBase64:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", fmt.Errorf("value is not valid base64: %w", err)
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q, expected utf8 or base64", encoding)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import "testing"
+
+func TestValueEncodingRoundTrip(t *testing.T) {
+	tests := []struct {
+		value    string
+		encoding string
+	}{
+		{"", encodingUTF8},
+		{"plain text", encodingUTF8},
+		{"héllo", encodingUTF8},
+		{"k8s\x00\xff\xfe\x01proto", encodingBase64},
+	}
+	for _, tt := range tests {
+		encoded, encoding := encodeValue([]byte(tt.value))
+		if encoding != tt.encoding {
+			t.Errorf("encodeValue(%q) encoding = %s, want %s", tt.value, encoding, tt.encoding)
+		}
+		if encoding == encodingUTF8 && encoded != tt.value {
+			t.Errorf("encodeValue(%q) = %q, want the value unchanged", tt.value, encoded)
+		}
+		decoded, err := decodeValue(encoded, encoding)
+		if err != nil || decoded != tt.value {
+			t.Errorf("decodeValue(%q, %s) = %q, %v; want %q", encoded, encoding, decoded, err, tt.value)
+		}
+	}
+}
+
+func TestDecodeValueErrors(t *testing.T) {
+	if _, err := decodeValue("!!", encodingBase64); err == nil {
+		t.Error("expected invalid base64 to fail")
+	}
+	if _, err := decodeValue("a", "hex"); err == nil {
+		t.Error("expected an unknown encoding to fail")
+	}
+	if v, err := decodeValue("a", ""); err != nil || v != "a" {
+		t.Errorf("empty encoding should default to utf8, got %q, %v", v, err)
+	}
+}
@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/zeromicro/go-zero/rest/httpx"
+	"gopkg.in/yaml.v2"
+
+	"etcd-manager/server/internal/svc"
+)
+
+// Structured value formats understood by the editor
+const (
+	formatJSON       = "json"
+	formatYAML       = "yaml"
+	formatTOML       = "toml"
+	formatProperties = "properties"
+)
+
+// yaml.v2 reports positions only as a line inside the message
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// valueFormatError locates a syntax error in a value. Line and Column are
+// 1-based; zero means the parser did not report them.
+type valueFormatError struct {
+	Format string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *valueFormatError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("invalid %s at line %d, column %d: %s", e.Format, e.Line, e.Column, e.Msg)
+	case e.Line > 0:
+		return fmt.Sprintf("invalid %s at line %d: %s", e.Format, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("invalid %s: %s", e.Format, e.Msg)
+}
+
+// valueErrorResponse adds the error position to the structured error response
+type valueErrorResponse struct {
+	ErrorResponse
+	Format string `json:"format"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// checkFormatName validates the format requested by a client
+func checkFormatName(format string) error {
+	switch format {
+	case "", formatJSON, formatYAML, formatTOML, formatProperties:
+		return nil
+	}
+	return fmt.Errorf("unsupported format %q, expected json, yaml, toml or properties", format)
+}
+
+// writeValueCheck validates value as format and writes a 400 locating the
+// first syntax error. It returns false when the value must not be stored.
+func writeValueCheck(w http.ResponseWriter, format, value string) bool {
+	if err := checkFormatName(format); err != nil {
+		BadRequest(w, err.Error())
+		return false
+	}
+	err := validateFormat(format, value)
+	if err == nil {
+		return true
+	}
+	var ferr *valueFormatError
+	if !errors.As(err, &ferr) {
+		BadRequest(w, err.Error())
+		return false
+	}
+	httpx.WriteJson(w, http.StatusBadRequest, valueErrorResponse{
+		ErrorResponse: ErrorResponse{Code: http.StatusBadRequest, Message: ferr.Error()},
+		Format:        ferr.Format,
+		Line:          ferr.Line,
+		Column:        ferr.Column,
+	})
+	return false
+}
+
+// validateFormat parses value as format, returning a *valueFormatError for
+// syntax errors. An empty format accepts any value.
+func validateFormat(format, value string) error {
+	switch format {
+	case "":
+		return nil
+	case formatJSON:
+		var v interface{}
+		err := json.Unmarshal([]byte(value), &v)
+		var serr *json.SyntaxError
+		if errors.As(err, &serr) {
+			line, col := lineColumn(value, int(serr.Offset))
+			return &valueFormatError{Format: format, Line: line, Column: col, Msg: serr.Error()}
+		}
+		if err != nil {
+			return &valueFormatError{Format: format, Msg: err.Error()}
+		}
+	case formatYAML:
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(value), &v); err != nil {
+			msg := strings.TrimPrefix(err.Error(), "yaml: ")
+			ferr := &valueFormatError{Format: format, Msg: msg}
+			if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+				ferr.Line, _ = strconv.Atoi(m[1])
+				ferr.Msg = strings.TrimPrefix(msg, m[0]+": ")
+			}
+			return ferr
+		}
+	case formatTOML:
+		var v map[string]interface{}
+		err := toml.Unmarshal([]byte(value), &v)
+		var derr *toml.DecodeError
+		if errors.As(err, &derr) {
+			line, col := derr.Position()
+			return &valueFormatError{Format: format, Line: line, Column: col, Msg: derr.Error()}
+		}
+		if err != nil {
+			return &valueFormatError{Format: format, Msg: err.Error()}
+		}
+	case formatProperties:
+		return validateProperties(value)
+	default:
+		return checkFormatName(format)
+	}
+	return nil
+}
+
+// lineColumn converts a byte offset just past an error into its line and column
+func lineColumn(s string, offset int) (int, int) {
+	if offset > len(s) {
+		offset = len(s)
+	}
+	if offset < 1 {
+		return 1, 1
+	}
+	// The offset points after the offending character
+	prefix := s[:offset-1]
+	line := strings.Count(prefix, "\n") + 1
+	start := strings.LastIndex(prefix, "\n") + 1
+	return line, utf8.RuneCountInString(prefix[start:]) + 1
+}
+
+// validateProperties checks Java properties syntax: every logical line is a
+// comment or has a non-empty key, and \u escapes carry four hex digits.
+func validateProperties(value string) error {
+	lines := strings.Split(value, "\n")
+	for i := 0; i < len(lines); i++ {
+		first := i + 1
+		line := strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		// Join continuation lines, which end in an odd number of backslashes
+		logical := line
+		for continued(logical) && i+1 < len(lines) {
+			i++
+			logical = logical[:len(logical)-1] + strings.TrimLeft(strings.TrimSuffix(lines[i], "\r"), " \t\f")
+		}
+		if logical[0] == '=' || logical[0] == ':' {
+			return &valueFormatError{Format: formatProperties, Line: first, Column: 1, Msg: "missing key before separator"}
+		}
+		for j := 0; j < len(logical); j++ {
+			if logical[j] != '\\' {
+				continue
+			}
+			if j+1 < len(logical) && logical[j+1] == 'u' {
+				if j+6 > len(logical) || !isHex(logical[j+2:j+6]) {
+					return &valueFormatError{Format: formatProperties, Line: first, Msg: `malformed \uXXXX escape`}
+				}
+			}
+			j++
+		}
+	}
+	return nil
+}
+
+// continued reports whether a properties line ends in an unescaped backslash
+func continued(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectFormat guesses the structured format of a value for the editor. Only
+// documents count: JSON objects or arrays, TOML with keys, YAML mappings or
+// sequences and properties made of key=value lines. Anything else is "".
+func detectFormat(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" || !utf8.ValidString(value) {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return formatJSON
+	}
+	var t map[string]interface{}
+	if toml.Unmarshal([]byte(value), &t) == nil && len(t) > 0 {
+		return formatTOML
+	}
+	var y interface{}
+	if yaml.Unmarshal([]byte(value), &y) == nil {
+		switch y.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return formatYAML
+		}
+	}
+	if looksLikeProperties(value) {
+		return formatProperties
+	}
+	return ""
+}
+
+// looksLikeProperties requires every entry to be key=value with a plain key
+func looksLikeProperties(value string) bool {
+	if validateProperties(value) != nil {
+		return false
+	}
+	entries := 0
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 || strings.ContainsAny(strings.TrimSpace(line[:eq]), " \t:") {
+			return false
+		}
+		entries++
+	}
+	return entries > 0
+}
+
+// prettyValue re-indents a JSON value. Other formats are returned unchanged,
+// since re-encoding them would drop comments and layout.
+func prettyValue(format, value string) (string, error) {
+	if err := validateFormat(format, value); err != nil {
+		return "", err
+	}
+	if format != formatJSON {
+		return value, nil
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(value), "", "  "); err != nil {
+		return "", err
+	}
+	if strings.HasSuffix(value, "\n") {
+		buf.WriteByte('\n')
+	}
+	return buf.String(), nil
+}
+
+type formatReq struct {
+	Value string `json:"value"`
+	// Format defaults to the detected format
+	Format string `json:"format,optional"`
+}
+
+type formatResp struct {
+	Format string `json:"format"`
+	Value  string `json:"value"`
+}
+
+// formatValue validates and pretty-prints a value without storing it
+func formatValue(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req formatReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := validateValue(req.Value); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+		format := req.Format
+		if format == "" {
+			format = detectFormat(req.Value)
+		}
+		if !writeValueCheck(w, format, req.Value) {
+			return
+		}
+		pretty, err := prettyValue(format, req.Value)
+		if err != nil {
+			InternalError(w, err)
+			return
+		}
+		httpx.OkJson(w, formatResp{Format: format, Value: pretty})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		value  string
+		line   int
+		column int
+		ok     bool
+	}{
+		{"json ok", formatJSON, `{"a": [1, 2]}`, 0, 0, true},
+		{"json trailing comma", formatJSON, "{\n  \"a\": 1,\n}", 3, 1, false},
+		{"json truncated", formatJSON, "{\n  \"a\": ", 0, 0, false},
+		{"yaml ok", formatYAML, "a:\n  b: 1\n", 0, 0, true},
+		{"yaml bad indent", formatYAML, "a:\n  b: 1\n c: 2\n", 2, 0, false},
+		{"toml ok", formatTOML, "[server]\nport = 80\n", 0, 0, true},
+		{"toml bad value", formatTOML, "[server]\nport = eighty\n", 2, 9, false},
+		{"properties ok", formatProperties, "# c\na=1\nb: 2\nc = multi \\\n  line\n", 0, 0, true},
+		{"properties no key", formatProperties, "a=1\n=2\n", 2, 1, false},
+		{"properties bad escape", formatProperties, "a=\\u12\n", 1, 0, false},
+		{"no format", "", "{", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFormat(tt.format, tt.value)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var ferr *valueFormatError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("expected *valueFormatError, got %v", err)
+			}
+			if tt.line != 0 && (ferr.Line != tt.line || ferr.Column != tt.column) {
+				t.Errorf("position = %d:%d, want %d:%d (%v)", ferr.Line, ferr.Column, tt.line, tt.column, err)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`{"a": 1}`, formatJSON},
+		{"[1, 2]", formatJSON},
+		{"title = \"x\"\n[owner]\nname = \"y\"\n", formatTOML},
+		{"a: 1\nb:\n  - x\n", formatYAML},
+		{"- a\n- b\n", formatYAML},
+		{"db.host=localhost\ndb.port=5432\n", formatProperties},
+		{"plain text", ""},
+		{"42", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := detectFormat(tt.value); got != tt.want {
+			t.Errorf("detectFormat(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteValueCheck(t *testing.T) {
+	w := httptest.NewRecorder()
+	if writeValueCheck(w, formatJSON, "{\"a\": }") {
+		t.Fatal("expected invalid JSON to be rejected")
+	}
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"line":1`) || !strings.Contains(w.Body.String(), `"column":7`) {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	if writeValueCheck(w, "xml", "<a/>") || w.Code != http.StatusBadRequest {
+		t.Errorf("expected an unsupported format to be rejected, got %d", w.Code)
+	}
+}
+
+func TestPrettyValue(t *testing.T) {
+	got, err := prettyValue(formatJSON, `{"a":[1,2]}`)
+	if err != nil {
+		t.Fatalf("prettyValue failed: %v", err)
+	}
+	if got != "{\n  \"a\": [\n    1,\n    2\n  ]\n}" {
+		t.Errorf("unexpected pretty JSON: %q", got)
+	}
+	if got, _ := prettyValue(formatYAML, "a:   1 # keep\n"); got != "a:   1 # keep\n" {
+		t.Errorf("expected YAML to be returned unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"etcd-manager/server/internal/svc"
+)
+
+// watchKeepAlive is the interval between SSE comment lines sent to keep
+// idle streams open through proxies
+const watchKeepAlive = 15 * time.Second
+
+type watchReq struct {
+	ConnID string `form:"connId"`
+	Key    string `form:"key"`
+	// Prefix watches every key under Key
+	Prefix bool `form:"prefix,optional"`
+	// Rev resumes the watch from this revision (inclusive)
+	Rev int64 `form:"rev,optional"`
+}
+
+// watchEvent is a single PUT/DELETE event sent to the browser
+type watchEvent struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	PrevValue string `json:"prevValue,omitempty"`
+	// Encoding and PrevEncoding are utf8, or base64 for binary values
+	Encoding       string `json:"encoding,omitempty"`
+	PrevEncoding   string `json:"prevEncoding,omitempty"`
+	HasPrev        bool   `json:"hasPrev"`
+	Revision       int64  `json:"revision"`
+	CreateRevision int64  `json:"createRevision"`
+	Version        int64  `json:"version"`
+	Lease          string `json:"lease,omitempty"` // hex lease id
+}
+
+// watchStartRev returns the revision a watch starts at. A Last-Event-ID sent
+// by a reconnecting EventSource resumes after that event and overrides rev.
+func watchStartRev(rev int64, lastEventID string) int64 {
+	if lastEventID != "" {
+		if id, err := strconv.ParseInt(lastEventID, 10, 64); err == nil {
+			return id + 1
+		}
+	}
+	return rev
+}
+
+// newWatchEvent converts an etcd event. Deletes carry no value; their lease
+// is taken from the previous value, since the tombstone has none.
+func newWatchEvent(ev *clientv3.Event) watchEvent {
+	e := watchEvent{
+		Key:            string(ev.Kv.Key),
+		Revision:       ev.Kv.ModRevision,
+		CreateRevision: ev.Kv.CreateRevision,
+		Version:        ev.Kv.Version,
+		Lease:          formatLeaseID(ev.Kv.Lease),
+	}
+	if ev.Type == clientv3.EventTypeDelete {
+		e.Type = "delete"
+	} else {
+		e.Type = "put"
+		e.Value, e.Encoding = encodeValue(ev.Kv.Value)
+	}
+	if ev.PrevKv != nil {
+		e.HasPrev = true
+		e.PrevValue, e.PrevEncoding = encodeValue(ev.PrevKv.Value)
+		if e.Lease == "" {
+			e.Lease = formatLeaseID(ev.PrevKv.Lease)
+		}
+	}
+	return e
+}
+
+// watchKeys streams etcd watch events as Server-Sent Events. Each event id is
+// its revision, so EventSource reconnects resume via Last-Event-ID.
+func watchKeys(ctx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req watchReq
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.Error(w, err)
+			return
+		}
+		if err := validateKey(req.Key); err != nil {
+			BadRequest(w, err.Error())
+			return
+		}
+
+		cli, ok := ctx.Manager.Client(req.ConnID)
+		if !ok {
+			BadRequest(w, "invalid connId or not connected")
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			InternalError(w, fmt.Errorf("streaming not supported"))
+			return
+		}
+
+		rev := watchStartRev(req.Rev, r.Header.Get("Last-Event-ID"))
+
+		opts := []clientv3.OpOption{clientv3.WithPrevKV()}
+		if req.Prefix {
+			opts = append(opts, clientv3.WithPrefix())
+		}
+		if rev > 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+
+		// The watch channel is closed when the browser goes away (request
+		// context) or when the connection is closed by Manager.Disconnect.
+		watchCtx := clientv3.WithRequireLeader(r.Context())
+		wch := cli.Watch(watchCtx, req.Key, opts...)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": watching\n\n")
+		flusher.Flush()
+
+		ticker := time.NewTicker(watchKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-ticker.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			case resp, ok := <-wch:
+				if !ok {
+					writeSSE(w, "closed", "", map[string]string{"message": "watch closed, connection was disconnected"})
+					flusher.Flush()
+					return
+				}
+				if resp.CompactRevision != 0 {
+					writeSSE(w, "error", "", map[string]interface{}{
+						"message":         "requested revision has been compacted",
+						"compactRevision": resp.CompactRevision,
+					})
+					flusher.Flush()
+					return
+				}
+				if err := resp.Err(); err != nil {
+					writeSSE(w, "error", "", map[string]string{"message": err.Error()})
+					flusher.Flush()
+					return
+				}
+				for _, ev := range resp.Events {
+					e := newWatchEvent(ev)
+					writeSSE(w, e.Type, strconv.FormatInt(e.Revision, 10), e)
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writeSSE writes one Server-Sent Event with a JSON payload
+func writeSSE(w http.ResponseWriter, event, id string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestWatchStartRev(t *testing.T) {
+	tests := []struct {
+		name string
+		rev  int64
+		last string
+		want int64
+	}{
+		{"from now", 0, "", 0},
+		{"requested revision", 5, "", 5},
+		{"resume after last event", 5, "41", 42},
+		{"invalid last event id", 5, "abc", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := watchStartRev(tt.rev, tt.last); got != tt.want {
+				t.Errorf("watchStartRev(%d, %q) = %d, want %d", tt.rev, tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWatchEvent(t *testing.T) {
+	t.Run("put", func(t *testing.T) {
+		e := newWatchEvent(&clientv3.Event{
+			Type:   clientv3.EventTypePut,
+			Kv:     &mvccpb.KeyValue{Key: []byte("/a"), Value: []byte{0xff, 0x01}, ModRevision: 7, CreateRevision: 3, Version: 2, Lease: 0x1f},
+			PrevKv: &mvccpb.KeyValue{Key: []byte("/a"), Value: []byte("old")},
+		})
+		if e.Type != "put" || e.Key != "/a" || e.Revision != 7 || e.CreateRevision != 3 || e.Version != 2 {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		if e.Value != "/wE=" || e.Encoding != encodingBase64 {
+			t.Errorf("value = %q (%s), want base64 /wE=", e.Value, e.Encoding)
+		}
+		if !e.HasPrev || e.PrevValue != "old" || e.PrevEncoding != encodingUTF8 {
+			t.Errorf("prev = %q (%s), has %v", e.PrevValue, e.PrevEncoding, e.HasPrev)
+		}
+		if e.Lease != "1f" {
+			t.Errorf("lease = %q, want 1f", e.Lease)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		e := newWatchEvent(&clientv3.Event{
+			Type:   clientv3.EventTypeDelete,
+			Kv:     &mvccpb.KeyValue{Key: []byte("/a"), ModRevision: 9},
+			PrevKv: &mvccpb.KeyValue{Key: []byte("/a"), Value: []byte("last"), Lease: 0x2a},
+		})
+		if e.Type != "delete" || e.Value != "" || e.Encoding != "" {
+			t.Errorf("unexpected delete event: %+v", e)
+		}
+		// The tombstone has no lease, so it comes from the previous value
+		if e.Lease != "2a" || e.PrevValue != "last" {
+			t.Errorf("lease = %q, prev = %q", e.Lease, e.PrevValue)
+		}
+	})
+
+	t.Run("delete without prev", func(t *testing.T) {
+		e := newWatchEvent(&clientv3.Event{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: []byte("/a"), ModRevision: 9}})
+		if e.HasPrev || e.Lease != "" {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	})
+}
+
+func TestWriteSSE(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeSSE(w, "put", "7", map[string]string{"key": "/a"})
+	writeSSE(w, "closed", "", map[string]string{"message": "bye"})
+	want := "id: 7\nevent: put\ndata: {\"key\":\"/a\"}\n\nevent: closed\ndata: {\"message\":\"bye\"}\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest"
+	"github.com/zeromicro/go-zero/rest/httpx"
+
+	"etcd-manager/server/internal/auth"
+	"etcd-manager/server/internal/model"
+)
+
+// AuthMiddleware authenticates API requests and enforces role-based access
+type AuthMiddleware struct {
+	enabled bool
+	users   *model.UserStore
+	tokens  *auth.Tokens
+}
+
+func NewAuthMiddleware(enabled bool, users *model.UserStore, tokens *auth.Tokens) *AuthMiddleware {
+	return &AuthMiddleware{
+		enabled: enabled,
+		users:   users,
+		tokens:  tokens,
+	}
+}
+
+// Enabled reports whether login is required
+func (m *AuthMiddleware) Enabled() bool {
+	return m.enabled
+}
+
+// Require returns a middleware that only lets users holding at least role through.
+// The user is looked up on every request so role changes and deletions apply immediately.
+func (m *AuthMiddleware) Require(role string) rest.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !m.enabled {
+				next(w, r)
+				return
+			}
+
+			claims, err := m.tokens.Parse(auth.TokenFromRequest(r))
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "authentication required")
+				return
+			}
+			user, ok := m.users.Get(claims.Username)
+			if !ok || user.TokenVersion != claims.Version {
+				writeError(w, http.StatusUnauthorized, "session is no longer valid")
+				return
+			}
+			if !model.RoleAllows(user.Role, role) {
+				writeError(w, http.StatusForbidden, "role "+role+" required")
+				return
+			}
+
+			next(w, r.WithContext(auth.WithUser(r.Context(), user)))
+		}
+	}
+}
+
+// writeError mirrors handler.ErrorResponse without importing the handler package
+func writeError(w http.ResponseWriter, code int, message string) {
+	httpx.WriteJson(w, code, map[string]interface{}{
+		"code":    code,
+		"message": message,
+	})
+}
@@ -18,13 +18,39 @@ import (
 )
 
 type Connection struct {
-    ID        string   `json:"id"`
-    Name      string   `json:"name"`
-    Endpoints []string `json:"endpoints"`
-    Username  string   `json:"username,omitempty"`
-    Password  string   `json:"password,omitempty"`
-    Status    string   `json:"status"` // connected, disconnected, error
-    UpdatedAt int64    `json:"updatedAt"`
+    ID                string     `json:"id"`
+    Name              string     `json:"name"`
+    Endpoints         []string   `json:"endpoints"`
+    Username          string     `json:"username,omitempty"`
+    Password          string     `json:"password,omitempty"`
+    TLS               *TLSConfig `json:"tls,omitempty"`
+    ReadOnly          bool       `json:"readOnly,omitempty"`          // reject every write made through the manager
+    ProtectedPrefixes []string   `json:"protectedPrefixes,omitempty"` // reject writes to keys under these prefixes
+    Status            string     `json:"status"`                      // connected, degraded, disconnected, error
+    UpdatedAt         int64      `json:"updatedAt"`
+}
+
+// Connection statuses. Degraded connections work, but an endpoint is down,
+// an alarm is raised or reads are slow.
+const (
+    StatusConnected    = "connected"
+    StatusDegraded     = "degraded"
+    StatusError        = "error"
+    StatusDisconnected = "disconnected"
+)
+
+// TLSConfig holds the TLS material for a connection. PEM content may be stored
+// inline (encrypted at rest) or referenced by a path inside the server's
+// certificate directory.
+type TLSConfig struct {
+    CACert             string `json:"caCert,omitempty"`         // PEM-encoded CA bundle
+    CACertFile         string `json:"caCertFile,omitempty"`     // path to CA bundle
+    ClientCert         string `json:"clientCert,omitempty"`     // PEM-encoded client certificate
+    ClientCertFile     string `json:"clientCertFile,omitempty"` // path to client certificate
+    ClientKey          string `json:"clientKey,omitempty"`      // PEM-encoded client private key
+    ClientKeyFile      string `json:"clientKeyFile,omitempty"`  // path to client private key
+    ServerName         string `json:"serverName,omitempty"`     // override for server name verification
+    InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
 }
 
 type ConnectionStore struct {
@@ -103,6 +129,19 @@ func (c *ConnectionStore) decrypt(ciphertext string) (string, error) {
     return string(plaintext), nil
 }
 
+// cryptTLS applies fn (encrypt or decrypt) to the inline PEM fields of t.
+// File references are stored as-is.
+func (c *ConnectionStore) cryptTLS(t *TLSConfig, fn func(string) (string, error)) error {
+    for _, field := range []*string{&t.CACert, &t.ClientCert, &t.ClientKey} {
+        out, err := fn(*field)
+        if err != nil {
+            return err
+        }
+        *field = out
+    }
+    return nil
+}
+
 func (c *ConnectionStore) load() error {
     c.mu.Lock()
     defer c.mu.Unlock()
@@ -133,6 +172,11 @@ func (c *ConnectionStore) load() error {
             }
             list[i].Password = decrypted
         }
+        if list[i].TLS != nil {
+            if err := c.cryptTLS(list[i].TLS, c.decrypt); err != nil {
+                return fmt.Errorf("failed to decrypt TLS material for connection %s: %w", list[i].ID, err)
+            }
+        }
     }
 
     c.list = list
@@ -151,6 +195,14 @@ func (c *ConnectionStore) save() error {
             }
             encrypted[i].Password = encryptedPass
         }
+        if conn.TLS != nil {
+            // Copy before encrypting so the in-memory list keeps plaintext PEM
+            t := *conn.TLS
+            if err := c.cryptTLS(&t, c.encrypt); err != nil {
+                return fmt.Errorf("failed to encrypt TLS material for connection %s: %w", conn.ID, err)
+            }
+            encrypted[i].TLS = &t
+        }
     }
 
     // Marshal to JSON
@@ -194,17 +246,22 @@ func (c *ConnectionStore) Get(id string) (Connection, bool) {
 }
 
 func (c *ConnectionStore) Add(name string, endpoints []string, username, password string) (Connection, error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    conn := Connection{
-        ID:        uuid.NewString(),
+    return c.Create(Connection{
         Name:      name,
         Endpoints: endpoints,
         Username:  username,
         Password:  password,
-        Status:    "disconnected",
-        UpdatedAt: time.Now().Unix(),
-    }
+    })
+}
+
+// Create stores a new connection, with its TLS and protection settings, in a
+// single save. The ID, status and update time are assigned by the store.
+func (c *ConnectionStore) Create(conn Connection) (Connection, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    conn.ID = uuid.NewString()
+    conn.Status = StatusDisconnected
+    conn.UpdatedAt = time.Now().Unix()
     c.list = append(c.list, conn)
     return conn, c.save()
 }
@@ -261,4 +318,23 @@ func (c *ConnectionStore) SetStatus(id, status string) error {
         }
     }
     return os.ErrNotExist
-}
\ No newline at end of file
+}
+
+// Modify applies fn to a connection and saves the result once, so a partial
+// change is never written to disk
+func (c *ConnectionStore) Modify(id string, fn func(*Connection)) (Connection, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    for i, v := range c.list {
+        if v.ID == id {
+            fn(&v)
+            v.UpdatedAt = time.Now().Unix()
+            c.list[i] = v
+            if err := c.save(); err != nil {
+                return Connection{}, err
+            }
+            return v, nil
+        }
+    }
+    return Connection{}, os.ErrNotExist
+}
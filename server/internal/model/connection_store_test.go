@@ -467,3 +467,78 @@ func TestRemoveConnection(t *testing.T) {
         t.Error("wrong connection persisted")
     }
 }
+
+// TestTLSMaterialEncryptedInFile verifies inline PEM content is encrypted at rest
+func TestTLSMaterialEncryptedInFile(t *testing.T) {
+    tempDir := t.TempDir()
+    secretKey := make([]byte, 32)
+    rand.Read(secretKey)
+
+    storePath := filepath.Join(tempDir, "connections.json")
+    store := NewConnectionStore(storePath, secretKey)
+
+    tlsCfg := &TLSConfig{
+        CACert:        "-----BEGIN CERTIFICATE-----\nca-material\n-----END CERTIFICATE-----\n",
+        ClientCert:    "-----BEGIN CERTIFICATE-----\ncert-material\n-----END CERTIFICATE-----\n",
+        ClientKeyFile: "prod/client-key.pem",
+        ServerName:    "etcd.internal",
+    }
+    conn, err := store.Create(Connection{Name: "tls", Endpoints: []string{"https://localhost:2379"}, TLS: tlsCfg})
+    if err != nil {
+        t.Fatalf("Create failed: %v", err)
+    }
+
+    data, _ := os.ReadFile(storePath)
+    content := string(data)
+    for _, secret := range []string{"ca-material", "cert-material"} {
+        if strings.Contains(content, secret) {
+            t.Errorf("plaintext %q found in storage file", secret)
+        }
+    }
+    if !strings.Contains(content, "prod/client-key.pem") {
+        t.Error("file reference should be stored as-is")
+    }
+
+    // In-memory copy must still hold plaintext
+    found, _ := store.Get(conn.ID)
+    if found.TLS == nil || found.TLS.CACert != tlsCfg.CACert {
+        t.Errorf("in-memory TLS config was modified: %+v", found.TLS)
+    }
+
+    // Restart and verify decryption
+    store2 := NewConnectionStore(storePath, secretKey)
+    found2, _ := store2.Get(conn.ID)
+    if found2.TLS == nil || *found2.TLS != *tlsCfg {
+        t.Errorf("TLS config not preserved after restart: %+v", found2.TLS)
+    }
+}
+
+// TestModifyConnection verifies several settings are changed in one save
+func TestModifyConnection(t *testing.T) {
+    tempDir := t.TempDir()
+    secretKey := make([]byte, 32)
+    rand.Read(secretKey)
+
+    storePath := filepath.Join(tempDir, "connections.json")
+    store := NewConnectionStore(storePath, secretKey)
+
+    conn, _ := store.Add("test", []string{"localhost:2379"}, "user1", "pass1")
+    tlsCfg := &TLSConfig{ServerName: "etcd.internal"}
+    _, err := store.Modify(conn.ID, func(v *Connection) {
+        v.Password = ""
+        v.TLS = tlsCfg
+        v.ReadOnly = true
+    })
+    if err != nil {
+        t.Fatalf("modify failed: %v", err)
+    }
+
+    found, _ := NewConnectionStore(storePath, secretKey).Get(conn.ID)
+    if found.Password != "" || found.TLS == nil || *found.TLS != *tlsCfg || !found.ReadOnly {
+        t.Errorf("modified connection not persisted: %+v", found)
+    }
+
+    if _, err := store.Modify("missing", func(*Connection) {}); !os.IsNotExist(err) {
+        t.Errorf("expected not-exist error, got %v", err)
+    }
+}
@@ -0,0 +1,272 @@
+package model
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "time"
+
+    "golang.org/x/crypto/bcrypt"
+)
+
+// Roles, from least to most privileged
+const (
+    RoleViewer = "viewer"
+    RoleEditor = "editor"
+    RoleAdmin  = "admin"
+)
+
+var roleRank = map[string]int{
+    RoleViewer: 1,
+    RoleEditor: 2,
+    RoleAdmin:  3,
+}
+
+var (
+    ErrUserExists      = errors.New("user already exists")
+    ErrInvalidRole     = errors.New("role must be one of viewer, editor, admin")
+    ErrInvalidPassword = errors.New("invalid username or password")
+    ErrLastAdmin       = errors.New("cannot remove or demote the last admin")
+)
+
+// dummyHash is compared against for unknown users so that they take as long
+// to reject as wrong passwords
+var (
+    dummyHash     []byte
+    dummyHashOnce sync.Once
+)
+
+// ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+    _, ok := roleRank[role]
+    return ok
+}
+
+// RoleAllows reports whether a user with role have may call a handler requiring need
+func RoleAllows(have, need string) bool {
+    return roleRank[have] >= roleRank[need] && roleRank[need] > 0
+}
+
+type User struct {
+    Username     string `json:"username"`
+    PasswordHash string `json:"passwordHash"`
+    Role         string `json:"role"`
+    // TokenVersion is bumped on password changes and logout to invalidate issued tokens
+    TokenVersion int   `json:"tokenVersion"`
+    CreatedAt    int64 `json:"createdAt"`
+    UpdatedAt    int64 `json:"updatedAt"`
+}
+
+// UserStore persists UI users with bcrypt password hashes
+type UserStore struct {
+    path string
+    mu   sync.RWMutex
+    list []User
+}
+
+// NewUserStore loads the users saved at path. A missing file is an empty
+// store; a file that cannot be read or parsed is an error, so that it is
+// never overwritten by a store that lost its users.
+func NewUserStore(path string) (*UserStore, error) {
+    us := &UserStore{path: path}
+    if err := us.load(); err != nil {
+        return nil, fmt.Errorf("failed to load users from %s: %w", path, err)
+    }
+    return us, nil
+}
+
+func (u *UserStore) load() error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+
+    _ = os.MkdirAll(filepath.Dir(u.path), 0o755)
+
+    data, err := os.ReadFile(u.path)
+    if err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            u.list = []User{}
+            return nil
+        }
+        return err
+    }
+
+    var list []User
+    if err := json.Unmarshal(data, &list); err != nil {
+        return err
+    }
+    u.list = list
+    return nil
+}
+
+func (u *UserStore) save() error {
+    data, err := json.MarshalIndent(u.list, "", "  ")
+    if err != nil {
+        return fmt.Errorf("failed to marshal users: %w", err)
+    }
+
+    // Atomic write: write to temp file then rename
+    tmpPath := u.path + ".tmp"
+    if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+        return fmt.Errorf("failed to write temp file: %w", err)
+    }
+    if err := os.Rename(tmpPath, u.path); err != nil {
+        _ = os.Remove(tmpPath)
+        return fmt.Errorf("failed to rename temp file: %w", err)
+    }
+    return nil
+}
+
+// Count returns the number of users
+func (u *UserStore) Count() int {
+    u.mu.RLock()
+    defer u.mu.RUnlock()
+    return len(u.list)
+}
+
+// List returns all users sorted by username
+func (u *UserStore) List() []User {
+    u.mu.RLock()
+    defer u.mu.RUnlock()
+    out := make([]User, len(u.list))
+    copy(out, u.list)
+    sort.Slice(out, func(i, j int) bool { return out[i].Username < out[j].Username })
+    return out
+}
+
+func (u *UserStore) Get(username string) (User, bool) {
+    u.mu.RLock()
+    defer u.mu.RUnlock()
+    for _, v := range u.list {
+        if v.Username == username {
+            return v, true
+        }
+    }
+    return User{}, false
+}
+
+// Authenticate checks a username/password pair against the stored hash
+func (u *UserStore) Authenticate(username, password string) (User, error) {
+    user, ok := u.Get(username)
+    if !ok {
+        dummyHashOnce.Do(func() {
+            dummyHash, _ = bcrypt.GenerateFromPassword([]byte("etcd-manager"), bcrypt.DefaultCost)
+        })
+        _ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+        return User{}, ErrInvalidPassword
+    }
+    if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+        return User{}, ErrInvalidPassword
+    }
+    return user, nil
+}
+
+func (u *UserStore) Add(username, password, role string) (User, error) {
+    if !ValidRole(role) {
+        return User{}, ErrInvalidRole
+    }
+    hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+    if err != nil {
+        return User{}, fmt.Errorf("failed to hash password: %w", err)
+    }
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    for _, v := range u.list {
+        if v.Username == username {
+            return User{}, ErrUserExists
+        }
+    }
+    now := time.Now().Unix()
+    user := User{
+        Username:     username,
+        PasswordHash: string(hash),
+        Role:         role,
+        CreatedAt:    now,
+        UpdatedAt:    now,
+    }
+    u.list = append(u.list, user)
+    return user, u.save()
+}
+
+// Update changes the role and/or password of a user. Empty values are left unchanged.
+func (u *UserStore) Update(username, password, role string) (User, error) {
+    if role != "" && !ValidRole(role) {
+        return User{}, ErrInvalidRole
+    }
+    var hash []byte
+    if password != "" {
+        var err error
+        hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+        if err != nil {
+            return User{}, fmt.Errorf("failed to hash password: %w", err)
+        }
+    }
+
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    for i, v := range u.list {
+        if v.Username == username {
+            if role != "" && role != RoleAdmin && v.Role == RoleAdmin && u.adminCount() == 1 {
+                return User{}, ErrLastAdmin
+            }
+            if role != "" {
+                v.Role = role
+            }
+            if hash != nil {
+                v.PasswordHash = string(hash)
+                v.TokenVersion++
+            }
+            v.UpdatedAt = time.Now().Unix()
+            u.list[i] = v
+            if err := u.save(); err != nil {
+                return User{}, err
+            }
+            return v, nil
+        }
+    }
+    return User{}, os.ErrNotExist
+}
+
+// RevokeTokens invalidates every token issued to a user so far
+func (u *UserStore) RevokeTokens(username string) error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    for i, v := range u.list {
+        if v.Username == username {
+            v.TokenVersion++
+            u.list[i] = v
+            return u.save()
+        }
+    }
+    return os.ErrNotExist
+}
+
+func (u *UserStore) Remove(username string) error {
+    u.mu.Lock()
+    defer u.mu.Unlock()
+    for i, v := range u.list {
+        if v.Username == username {
+            if v.Role == RoleAdmin && u.adminCount() == 1 {
+                return ErrLastAdmin
+            }
+            u.list = append(u.list[:i], u.list[i+1:]...)
+            return u.save()
+        }
+    }
+    return os.ErrNotExist
+}
+
+// adminCount returns the number of admins. Callers must hold u.mu.
+func (u *UserStore) adminCount() int {
+    n := 0
+    for _, v := range u.list {
+        if v.Role == RoleAdmin {
+            n++
+        }
+    }
+    return n
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func newTestUserStore(t *testing.T, path string) *UserStore {
+    t.Helper()
+    store, err := NewUserStore(path)
+    if err != nil {
+        t.Fatalf("failed to load users: %v", err)
+    }
+    return store
+}
+
+// TestUserStoreCorruptFile verifies an unreadable users file is reported and left alone
+func TestUserStoreCorruptFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "users.json")
+    corrupt := []byte(`[{"username": "alice", "passwordHash": `)
+    if err := os.WriteFile(path, corrupt, 0o600); err != nil {
+        t.Fatalf("failed to write file: %v", err)
+    }
+
+    if store, err := NewUserStore(path); err == nil {
+        t.Fatalf("expected an error for a corrupt users file, got a store with %d users", store.Count())
+    }
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("failed to read file: %v", err)
+    }
+    if string(data) != string(corrupt) {
+        t.Errorf("users file was modified: %q", data)
+    }
+}
+
+// TestUserStoreAuthenticate verifies passwords are hashed and checked
+func TestUserStoreAuthenticate(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "users.json")
+    store := newTestUserStore(t, path)
+
+    if _, err := store.Add("alice", "correct-horse", RoleEditor); err != nil {
+        t.Fatalf("failed to add user: %v", err)
+    }
+
+    data, err := os.ReadFile(path)
+    if err != nil {
+        t.Fatalf("failed to read file: %v", err)
+    }
+    if strings.Contains(string(data), "correct-horse") {
+        t.Error("plaintext password found in storage file")
+    }
+
+    // Reload from disk
+    store = newTestUserStore(t, path)
+    user, err := store.Authenticate("alice", "correct-horse")
+    if err != nil {
+        t.Fatalf("authenticate failed: %v", err)
+    }
+    if user.Role != RoleEditor {
+        t.Errorf("expected role %q, got %q", RoleEditor, user.Role)
+    }
+    if _, err := store.Authenticate("alice", "wrong"); !errors.Is(err, ErrInvalidPassword) {
+        t.Errorf("expected ErrInvalidPassword for wrong password, got %v", err)
+    }
+    if _, err := store.Authenticate("bob", "correct-horse"); !errors.Is(err, ErrInvalidPassword) {
+        t.Errorf("expected ErrInvalidPassword for unknown user, got %v", err)
+    }
+}
+
+// TestUserStorePasswordChangeBumpsTokenVersion verifies old sessions are invalidated
+func TestUserStorePasswordChangeBumpsTokenVersion(t *testing.T) {
+    store := newTestUserStore(t, filepath.Join(t.TempDir(), "users.json"))
+    before, err := store.Add("alice", "password-1", RoleViewer)
+    if err != nil {
+        t.Fatalf("failed to add user: %v", err)
+    }
+
+    after, err := store.Update("alice", "", RoleEditor)
+    if err != nil {
+        t.Fatalf("failed to update role: %v", err)
+    }
+    if after.TokenVersion != before.TokenVersion {
+        t.Error("role change should not bump token version")
+    }
+
+    after, err = store.Update("alice", "password-2", "")
+    if err != nil {
+        t.Fatalf("failed to update password: %v", err)
+    }
+    if after.TokenVersion != before.TokenVersion+1 {
+        t.Errorf("expected token version %d, got %d", before.TokenVersion+1, after.TokenVersion)
+    }
+    if after.Role != RoleEditor {
+        t.Errorf("empty role should keep %q, got %q", RoleEditor, after.Role)
+    }
+}
+
+// TestUserStoreRevokeTokens verifies logging out invalidates issued tokens
+func TestUserStoreRevokeTokens(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "users.json")
+    store := newTestUserStore(t, path)
+    before, err := store.Add("alice", "password-1", RoleViewer)
+    if err != nil {
+        t.Fatalf("failed to add user: %v", err)
+    }
+
+    if err := store.RevokeTokens("alice"); err != nil {
+        t.Fatalf("failed to revoke tokens: %v", err)
+    }
+    after, _ := newTestUserStore(t, path).Get("alice")
+    if after.TokenVersion != before.TokenVersion+1 {
+        t.Errorf("expected token version %d, got %d", before.TokenVersion+1, after.TokenVersion)
+    }
+    if err := store.RevokeTokens("bob"); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("expected not-exist error, got %v", err)
+    }
+}
+
+// TestUserStoreLastAdmin verifies the last admin cannot be removed or demoted
+func TestUserStoreLastAdmin(t *testing.T) {
+    store := newTestUserStore(t, filepath.Join(t.TempDir(), "users.json"))
+    if _, err := store.Add("root", "password-1", RoleAdmin); err != nil {
+        t.Fatalf("failed to add user: %v", err)
+    }
+
+    if err := store.Remove("root"); !errors.Is(err, ErrLastAdmin) {
+        t.Errorf("expected ErrLastAdmin on remove, got %v", err)
+    }
+    if _, err := store.Update("root", "", RoleViewer); !errors.Is(err, ErrLastAdmin) {
+        t.Errorf("expected ErrLastAdmin on demote, got %v", err)
+    }
+
+    if _, err := store.Add("root2", "password-2", RoleAdmin); err != nil {
+        t.Fatalf("failed to add second admin: %v", err)
+    }
+    if err := store.Remove("root"); err != nil {
+        t.Errorf("removing one of two admins should succeed: %v", err)
+    }
+    if _, err := store.Add("root2", "password-3", RoleViewer); !errors.Is(err, ErrUserExists) {
+        t.Errorf("expected ErrUserExists, got %v", err)
+    }
+    if _, err := store.Add("carol", "password-4", "superuser"); !errors.Is(err, ErrInvalidRole) {
+        t.Errorf("expected ErrInvalidRole, got %v", err)
+    }
+}
+
+func TestRoleAllows(t *testing.T) {
+    tests := []struct {
+        have, need string
+        want       bool
+    }{
+        {RoleViewer, RoleViewer, true},
+        {RoleViewer, RoleEditor, false},
+        {RoleEditor, RoleViewer, true},
+        {RoleEditor, RoleAdmin, false},
+        {RoleAdmin, RoleEditor, true},
+        {"", RoleViewer, false},
+        {RoleAdmin, "", false},
+    }
+    for _, tt := range tests {
+        if got := RoleAllows(tt.have, tt.need); got != tt.want {
+            t.Errorf("RoleAllows(%q, %q) = %v, want %v", tt.have, tt.need, got, tt.want)
+        }
+    }
+}
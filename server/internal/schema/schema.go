@@ -0,0 +1,316 @@
+// Package schema binds JSON Schemas to key patterns and validates the values
+// written under them.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var (
+	ErrNotFound = errors.New("schema binding not found")
+	// ErrInvalid is wrapped by errors about invalid patterns and schemas
+	ErrInvalid = errors.New("invalid schema binding")
+	// errUnavailable is returned when changing a nil store
+	errUnavailable = errors.New("schemas could not be loaded, see the server log")
+)
+
+// Binding applies a JSON Schema to the values of keys matching Pattern
+type Binding struct {
+	ID string `json:"id"`
+	// Pattern is a key prefix whose segments may be path.Match globs, such as
+	// /services/*/config. It matches the key itself and every key below it.
+	Pattern string `json:"pattern"`
+	// ConnID limits the binding to one connection; empty applies to all
+	ConnID      string          `json:"connId,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	UpdatedAt   int64           `json:"updatedAt"`
+}
+
+// Violation is a single schema error in a value
+type Violation struct {
+	BindingID string `json:"bindingId"`
+	Pattern   string `json:"pattern"`
+	// Path is the JSON pointer of the offending part of the value
+	Path string `json:"path"`
+	// Keyword is the JSON pointer of the failed keyword in the schema
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// Store persists bindings and keeps their compiled schemas. A nil *Store
+// has no bindings.
+type Store struct {
+	path string
+
+	mu       sync.RWMutex
+	list     []Binding
+	compiled map[string]*jsonschema.Schema
+}
+
+// NewStore loads the bindings saved at path
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, compiled: map[string]*jsonschema.Schema{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schemas: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.list); err != nil {
+		return nil, fmt.Errorf("failed to parse schemas: %w", err)
+	}
+	for _, b := range s.list {
+		sch, err := Compile(b.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("schema for %q: %w", b.Pattern, err)
+		}
+		s.compiled[b.ID] = sch
+	}
+	return s, nil
+}
+
+// Compile parses a JSON Schema document. References to other documents are
+// not resolved, so a schema cannot read files or URLs.
+func Compile(raw json.RawMessage) (*jsonschema.Schema, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, errors.New("schema cannot be empty")
+	}
+	c := jsonschema.NewCompiler()
+	c.AssertFormat = true
+	c.LoadURL = func(s string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external schema references are not supported: %s", s)
+	}
+	const url = "binding.json"
+	if err := c.AddResource(url, bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	sch, err := c.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return sch, nil
+}
+
+// ValidatePattern checks the syntax of a binding pattern
+func ValidatePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return errors.New("pattern must start with '/'")
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether key, or one of its parent directories, matches pattern
+// segment by segment
+func Match(pattern, key string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "" {
+		return true
+	}
+	psegs := strings.Split(pattern, "/")
+	ksegs := strings.Split(key, "/")
+	if len(ksegs) < len(psegs) {
+		return false
+	}
+	for i, p := range psegs {
+		if ok, _ := path.Match(p, ksegs[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns the bindings ordered by pattern
+func (s *Store) List() []Binding {
+	if s == nil {
+		return []Binding{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Binding, len(s.list))
+	copy(out, s.list)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Pattern < out[j].Pattern })
+	return out
+}
+
+// Add validates and stores a new binding
+func (s *Store) Add(b Binding) (Binding, error) {
+	if s == nil {
+		return Binding{}, errUnavailable
+	}
+	sch, err := checkBinding(b)
+	if err != nil {
+		return Binding{}, err
+	}
+	b.ID = uuid.NewString()
+	b.UpdatedAt = time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append(append([]Binding(nil), s.list...), b)
+	if err := s.save(list); err != nil {
+		return Binding{}, err
+	}
+	s.list = list
+	s.compiled[b.ID] = sch
+	return b, nil
+}
+
+// Update replaces the binding with b.ID
+func (s *Store) Update(b Binding) (Binding, error) {
+	if s == nil {
+		return Binding{}, errUnavailable
+	}
+	sch, err := checkBinding(b)
+	if err != nil {
+		return Binding{}, err
+	}
+	b.UpdatedAt = time.Now().Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := append([]Binding(nil), s.list...)
+	for i := range list {
+		if list[i].ID == b.ID {
+			list[i] = b
+			if err := s.save(list); err != nil {
+				return Binding{}, err
+			}
+			s.list = list
+			s.compiled[b.ID] = sch
+			return b, nil
+		}
+	}
+	return Binding{}, ErrNotFound
+}
+
+// Remove deletes the binding with id
+func (s *Store) Remove(id string) error {
+	if s == nil {
+		return errUnavailable
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.list {
+		if s.list[i].ID == id {
+			list := append(append([]Binding(nil), s.list[:i]...), s.list[i+1:]...)
+			if err := s.save(list); err != nil {
+				return err
+			}
+			s.list = list
+			delete(s.compiled, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func checkBinding(b Binding) (*jsonschema.Schema, error) {
+	if err := ValidatePattern(b.Pattern); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	sch, err := Compile(b.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return sch, nil
+}
+
+func (s *Store) save(list []Binding) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write schemas: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write schemas: %w", err)
+	}
+	return nil
+}
+
+// Applies reports whether any binding covers key on connection connID
+func (s *Store) Applies(connID, key string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, b := range s.list {
+		if applies(b, connID, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func applies(b Binding, connID, key string) bool {
+	return (b.ConnID == "" || b.ConnID == connID) && Match(b.Pattern, key)
+}
+
+// Validate checks doc, a decoded JSON value, against every binding that
+// covers key on connection connID
+func (s *Store) Validate(connID, key string, doc interface{}) []Violation {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Violation
+	for _, b := range s.list {
+		if !applies(b, connID, key) {
+			continue
+		}
+		err := s.compiled[b.ID].Validate(doc)
+		var ve *jsonschema.ValidationError
+		if errors.As(err, &ve) {
+			out = append(out, leafViolations(b, ve)...)
+		} else if err != nil {
+			out = append(out, Violation{BindingID: b.ID, Pattern: b.Pattern, Message: err.Error()})
+		}
+	}
+	return out
+}
+
+// leafViolations flattens a validation error to its most specific causes
+func leafViolations(b Binding, ve *jsonschema.ValidationError) []Violation {
+	if len(ve.Causes) == 0 {
+		return []Violation{{
+			BindingID: b.ID,
+			Pattern:   b.Pattern,
+			Path:      ve.InstanceLocation,
+			Keyword:   ve.KeywordLocation,
+			Message:   ve.Message,
+		}}
+	}
+	var out []Violation
+	for _, c := range ve.Causes {
+		out = append(out, leafViolations(b, c)...)
+	}
+	return out
+}
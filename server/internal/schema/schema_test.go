@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+const serviceSchema = `{
+	"type": "object",
+	"required": ["port"],
+	"properties": {
+		"port": {"type": "integer", "minimum": 1},
+		"hosts": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"/services/*/config", "/services/api/config", true},
+		{"/services/*/config", "/services/api/config/db", true},
+		{"/services/*/config", "/services/api/configs", false},
+		{"/services/*/config", "/services/config", false},
+		{"/services/", "/services/api", true},
+		{"/", "/anything", true},
+		{"/app/v[12]", "/app/v2/x", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestStoreValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schemas.json")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	b, err := s.Add(Binding{Pattern: "/services/*/config", Schema: json.RawMessage(serviceSchema)})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, err := s.Add(Binding{Pattern: "/other", ConnID: "c2", Schema: json.RawMessage(`{"type": "string"}`)}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	var doc interface{}
+	json.Unmarshal([]byte(`{"port": 0, "hosts": ["a", 1]}`), &doc)
+	got := s.Validate("c1", "/services/api/config", doc)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 violations, got %+v", got)
+	}
+	paths := map[string]bool{}
+	for _, v := range got {
+		paths[v.Path] = true
+		if v.BindingID != b.ID || v.Message == "" {
+			t.Errorf("unexpected violation %+v", v)
+		}
+	}
+	if !paths["/port"] || !paths["/hosts/1"] {
+		t.Errorf("unexpected violation paths: %v", paths)
+	}
+
+	if !s.Applies("c1", "/services/api/config") || s.Applies("c1", "/other") || !s.Applies("c2", "/other") {
+		t.Error("unexpected Applies result")
+	}
+
+	// Bindings and compiled schemas survive a reload
+	reloaded, err := NewStore(path)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if len(reloaded.List()) != 2 || len(reloaded.Validate("c1", "/services/api/config", doc)) != 2 {
+		t.Error("reloaded store does not match")
+	}
+
+	if err := s.Remove(b.ID); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := s.Remove(b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAddRejectsInvalidBindings(t *testing.T) {
+	s, err := NewStore(filepath.Join(t.TempDir(), "schemas.json"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	bad := []Binding{
+		{Pattern: "services", Schema: json.RawMessage(`{}`)},
+		{Pattern: "/a/[", Schema: json.RawMessage(`{}`)},
+		{Pattern: "/a", Schema: json.RawMessage(`{"type": 5}`)},
+		{Pattern: "/a", Schema: json.RawMessage(`{"$ref": "file:///etc/passwd"}`)},
+		{Pattern: "/a"},
+	}
+	for _, b := range bad {
+		if _, err := s.Add(b); err == nil {
+			t.Errorf("expected Add(%+v) to fail", b)
+		}
+	}
+	if len(s.List()) != 0 {
+		t.Error("rejected bindings must not be stored")
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	if s.Applies("c", "/a") || s.Validate("c", "/a", nil) != nil || len(s.List()) != 0 {
+		t.Error("a nil store must have no bindings")
+	}
+}
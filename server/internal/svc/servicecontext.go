@@ -1,14 +1,22 @@
 package svc
 
 import (
+    "crypto/hmac"
     "crypto/rand"
+    "crypto/sha256"
     "encoding/base64"
     "os"
     "path/filepath"
+    "time"
 
+    "etcd-manager/server/internal/audit"
+    "etcd-manager/server/internal/auth"
+    "etcd-manager/server/internal/backup"
     "etcd-manager/server/internal/config"
     "etcd-manager/server/internal/etcd"
+    "etcd-manager/server/internal/middleware"
     "etcd-manager/server/internal/model"
+    "etcd-manager/server/internal/schema"
 
     "github.com/zeromicro/go-zero/core/logx"
 )
@@ -17,8 +25,22 @@ type ServiceContext struct {
     Config  config.Config
     Store   *model.ConnectionStore
     Manager *etcd.Manager
+    Users   *model.UserStore
+    Tokens  *auth.Tokens
+    Auth    *middleware.AuthMiddleware
+    // Audit is nil when auditing is disabled
+    Audit *audit.Log
+    // Confirmations holds the pending confirmations of maintenance operations
+    Confirmations *auth.Confirmations
+    // Schemas validates values written under bound key patterns
+    Schemas *schema.Store
+    // Backups is nil when no connection is configured for scheduled backups
+    Backups *backup.Scheduler
 }
 
+// confirmationTTL is how long a maintenance preview can be confirmed
+const confirmationTTL = 2 * time.Minute
+
 func NewServiceContext(c config.Config) *ServiceContext {
     dataPath := c.DataPath
     if dataPath == "" {
@@ -69,12 +91,112 @@ func NewServiceContext(c config.Config) *ServiceContext {
     }
 
     store := model.NewConnectionStore(filepath.Join(dataPath, "connections.json"), secretKey)
-    mgr := etcd.NewManager(store)
+    mgr := etcd.NewManager(store, filepath.Join(dataPath, "certs"))
+    mgr.StartMonitor(etcd.MonitorConfig{
+        Interval:    time.Duration(c.Health.Interval) * time.Second,
+        Timeout:     time.Duration(c.Health.Timeout) * time.Second,
+        MaxBackoff:  time.Duration(c.Health.MaxBackoff) * time.Second,
+        SlowLatency: time.Duration(c.Health.SlowMs) * time.Millisecond,
+    })
+    // The saved statuses outlive the clients of the previous run
+    mgr.Restore(!c.Health.DisableRestore)
+
+    // Starting without the saved users would bootstrap a new admin over them
+    users, err := model.NewUserStore(filepath.Join(dataPath, "users.json"))
+    logx.Must(err)
+    if !c.Auth.Disabled {
+        bootstrapAdmin(users, c.Auth.AdminPassword)
+    } else {
+        logx.Info("WARNING: authentication is disabled, every API request is treated as admin")
+    }
+
+    // Token signing key: explicit secret, or derived from the encryption key
+    var tokenSecret []byte
+    if c.Auth.AccessSecret != "" {
+        decoded, err := base64.StdEncoding.DecodeString(c.Auth.AccessSecret)
+        if err != nil || len(decoded) < 32 {
+            logx.Error("Invalid Auth.AccessSecret (must be base64, at least 32 bytes), deriving from secret key")
+        } else {
+            tokenSecret = decoded
+        }
+    }
+    if tokenSecret == nil {
+        mac := hmac.New(sha256.New, secretKey)
+        mac.Write([]byte("etcd-manager session tokens"))
+        tokenSecret = mac.Sum(nil)
+    }
+    expire := time.Duration(c.Auth.AccessExpire) * time.Second
+    if expire <= 0 {
+        expire = 24 * time.Hour
+    }
+    tokens := auth.NewTokens(tokenSecret, expire)
+
+    var auditLog *audit.Log
+    if !c.Audit.Disabled {
+        var err error
+        auditLog, err = audit.New(filepath.Join(dataPath, "audit"), c.Audit.MaxSizeMB<<20, c.Audit.MaxBackups)
+        if err != nil {
+            logx.Errorf("Failed to open audit log, auditing is disabled: %v", err)
+        }
+    }
+
+    // Without the saved bindings every write would skip schema validation
+    schemas, err := schema.NewStore(filepath.Join(dataPath, "schemas.json"))
+    logx.Must(err)
+
+    var backups *backup.Scheduler
+    if len(c.Backup.Connections) > 0 {
+        var err error
+        interval := time.Duration(c.Backup.Interval) * time.Second
+        backups, err = backup.New(filepath.Join(dataPath, "backups"), mgr, store, c.Backup.Connections, interval, c.Backup.Retention)
+        if err != nil {
+            logx.Errorf("Failed to set up scheduled backups, backups are disabled: %v", err)
+        } else {
+            backups.Start()
+        }
+    }
 
     return &ServiceContext{
         Config:  c,
         Store:   store,
         Manager: mgr,
+        Users:   users,
+        Tokens:  tokens,
+        Auth:    middleware.NewAuthMiddleware(!c.Auth.Disabled, users, tokens),
+        Audit:   auditLog,
+
+        Confirmations: auth.NewConfirmations(confirmationTTL),
+        Schemas:       schemas,
+        Backups:       backups,
+    }
+}
+
+// bootstrapAdmin creates the initial admin user when no users exist
+func bootstrapAdmin(users *model.UserStore, password string) {
+    if users.Count() > 0 {
+        return
+    }
+    if password == "" {
+        password = os.Getenv("ETCD_MANAGER_ADMIN_PASSWORD")
+    }
+    generated := password == ""
+    if generated {
+        buf := make([]byte, 12)
+        if _, err := rand.Read(buf); err != nil {
+            logx.Errorf("Failed to generate admin password: %v", err)
+            return
+        }
+        password = base64.RawURLEncoding.EncodeToString(buf)
+    }
+    if _, err := users.Add("admin", password, model.RoleAdmin); err != nil {
+        logx.Errorf("Failed to create initial admin user: %v", err)
+        return
+    }
+    if generated {
+        logx.Infof("Created initial admin user 'admin' with password: %s", password)
+        logx.Info("IMPORTANT: Change this password after the first login")
+    } else {
+        logx.Info("Created initial admin user 'admin'")
     }
 }
 
@@ -5,6 +5,7 @@ import { Sidebar } from '@/components/Layout/Sidebar';
 import { ErrorBoundary } from '@/components/ErrorBoundary';
 import { Connections } from '@/pages/Connections';
 import { Explorer } from '@/pages/Explorer';
+import { Login } from '@/pages/Login';
 
 const { Content } = Layout;
 
@@ -19,28 +20,36 @@ function App() {
     >
       <ErrorBoundary>
         <BrowserRouter>
-          <Layout style={{ minHeight: '100vh' }}>
-            <Header />
-            <Layout>
-              <Sidebar />
-              <Layout style={{ padding: '24px' }}>
-                <Content
-                  style={{
-                    background: '#fff',
-                    padding: 24,
-                    margin: 0,
-                    minHeight: 280,
-                  }}
-                >
-                  <Routes>
-                    <Route path="/" element={<Navigate to="/connections" replace />} />
-                    <Route path="/connections" element={<Connections />} />
-                    <Route path="/explorer" element={<Explorer />} />
-                  </Routes>
-                </Content>
-              </Layout>
-            </Layout>
-          </Layout>
+          <Routes>
+            <Route path="/login" element={<Login />} />
+            <Route
+              path="*"
+              element={
+                <Layout style={{ minHeight: '100vh' }}>
+                  <Header />
+                  <Layout>
+                    <Sidebar />
+                    <Layout style={{ padding: '24px' }}>
+                      <Content
+                        style={{
+                          background: '#fff',
+                          padding: 24,
+                          margin: 0,
+                          minHeight: 280,
+                        }}
+                      >
+                        <Routes>
+                          <Route path="/" element={<Navigate to="/connections" replace />} />
+                          <Route path="/connections" element={<Connections />} />
+                          <Route path="/explorer" element={<Explorer />} />
+                        </Routes>
+                      </Content>
+                    </Layout>
+                  </Layout>
+                </Layout>
+              }
+            />
+          </Routes>
         </BrowserRouter>
       </ErrorBoundary>
     </ConfigProvider>
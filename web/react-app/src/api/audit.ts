@@ -0,0 +1,9 @@
+import apiClient from './client';
+import type { AuditQuery, AuditResp } from '@/types/audit';
+
+export const auditApi = {
+  async query(params: AuditQuery = {}): Promise<AuditResp> {
+    const response = await apiClient.get('/audit', { params });
+    return response.data;
+  },
+};
@@ -0,0 +1,48 @@
+import apiClient from './client';
+import type { AddUserReq, LoginResp, MeResp, UpdateUserReq, User } from '@/types/auth';
+
+// The session token is also set as an HttpOnly cookie, so requests made by
+// the browser (including EventSource) are authenticated without extra headers.
+export const authApi = {
+  async login(username: string, password: string): Promise<LoginResp> {
+    const response = await apiClient.post('/auth/login', { username, password });
+    return response.data;
+  },
+
+  async logout(): Promise<void> {
+    await apiClient.post('/auth/logout');
+  },
+
+  async me(): Promise<MeResp> {
+    const response = await apiClient.get('/auth/me');
+    return response.data;
+  },
+
+  async changePassword(oldPassword: string, newPassword: string): Promise<LoginResp> {
+    const response = await apiClient.post('/auth/password', { oldPassword, newPassword });
+    return response.data;
+  },
+};
+
+export const usersApi = {
+  async list(): Promise<User[]> {
+    const response = await apiClient.get('/users');
+    return response.data;
+  },
+
+  async add(data: AddUserReq): Promise<User> {
+    const response = await apiClient.post('/users', data);
+    return response.data;
+  },
+
+  async update(data: UpdateUserReq): Promise<User> {
+    const response = await apiClient.put('/users', data);
+    return response.data;
+  },
+
+  async remove(username: string): Promise<void> {
+    await apiClient.delete('/users', {
+      params: { username },
+    });
+  },
+};
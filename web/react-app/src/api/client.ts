@@ -28,8 +28,17 @@ apiClient.interceptors.response.use(
     if (error.response) {
       const { status, data } = error.response;
 
-      if (status === 400) {
+      if (status === 401) {
+        // Session missing or expired: go to the login page
+        if (window.location.pathname !== '/login') {
+          window.location.assign('/login');
+          return Promise.reject(error);
+        }
+        message.error(data.message || 'Authentication failed');
+      } else if (status === 400) {
         message.error(data.message || 'Invalid request');
+      } else if (status === 403) {
+        message.error(data.message || 'Permission denied');
       } else if (status === 404) {
         message.error(data.message || 'Resource not found');
       } else if (status === 500) {
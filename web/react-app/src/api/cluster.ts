@@ -0,0 +1,55 @@
+import apiClient from './client';
+import type {
+  ClusterResp,
+  MemberItem,
+  CompactReq,
+  DefragmentReq,
+  MaintenanceResp,
+  BackupListResp,
+} from '@/types/cluster';
+
+export const clusterApi = {
+  async status(connId: string): Promise<ClusterResp> {
+    const response = await apiClient.get('/cluster', {
+      params: { connId },
+    });
+    return response.data;
+  },
+
+  async addMember(connId: string, peerURLs: string[], learner = false): Promise<MemberItem> {
+    const response = await apiClient.post('/cluster/members', { connId, peerURLs, learner, confirm: true });
+    return response.data;
+  },
+
+  async removeMember(connId: string, id: string): Promise<void> {
+    await apiClient.post('/cluster/members/remove', { connId, id, confirm: true });
+  },
+
+  async promoteMember(connId: string, id: string): Promise<void> {
+    await apiClient.post('/cluster/members/promote', { connId, id, confirm: true });
+  },
+
+  async compact(req: CompactReq): Promise<MaintenanceResp> {
+    const response = await apiClient.post('/cluster/compact', req);
+    return response.data;
+  },
+
+  async defragment(req: DefragmentReq): Promise<MaintenanceResp> {
+    const response = await apiClient.post('/cluster/defragment', req);
+    return response.data;
+  },
+
+  // URL of a streamed snapshot download; open it in a link or window to save the file
+  snapshotUrl(connId: string, endpoint?: string): string {
+    const params = new URLSearchParams({ connId });
+    if (endpoint) {
+      params.set('endpoint', endpoint);
+    }
+    return `${apiClient.defaults.baseURL}/cluster/snapshot?${params.toString()}`;
+  },
+
+  async backups(connId?: string): Promise<BackupListResp> {
+    const response = await apiClient.get('/backups', { params: { connId } });
+    return response.data;
+  },
+};
@@ -1,3 +1,8 @@
 export { connectionsApi } from './connections';
 export { kvApi } from './kv';
+export { leasesApi } from './leases';
+export { clusterApi } from './cluster';
+export { authApi, usersApi } from './auth';
+export { auditApi } from './audit';
+export { schemaApi } from './schema';
 export { default as apiClient } from './client';
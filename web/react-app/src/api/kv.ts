@@ -1,13 +1,27 @@
 import apiClient from './client';
 import type {
+  ExportFormat,
+  ImportOptions,
+  DiffReq,
+  DiffResp,
+  SyncReq,
+  RevisionAtResp,
+  RestoreReq,
+  RestoreResp,
   KeyItem,
   ListKeysResp,
   PutKeyReq,
+  PutKeyResp,
+  TxnReq,
+  TxnResp,
   RenameKeyReq,
   CopyKeyReq,
   BatchDeleteReq,
   GetHistoryResp,
-  RollbackReq
+  RollbackReq,
+  BulkResp,
+  FormatValueReq,
+  FormatValueResp,
 } from '@/types/kv';
 
 export const kvApi = {
@@ -18,21 +32,43 @@ export const kvApi = {
     return response.data;
   },
 
-  async get(connId: string, key: string): Promise<KeyItem> {
+  // rev > 0 browses the tree as of that revision
+  async listPage(connId: string, prefix: string, limit: number, cursor?: string, includeTTL = false, rev?: number): Promise<ListKeysResp> {
+    const response = await apiClient.get('/kv/list', {
+      params: { connId, prefix, includeTTL, limit, cursor, rev },
+    });
+    return response.data;
+  },
+
+  async get(connId: string, key: string, rev?: number): Promise<KeyItem> {
     const response = await apiClient.get('/kv', {
-      params: { connId, key },
+      params: { connId, key, rev },
     });
     return response.data;
   },
 
-  async put(data: PutKeyReq): Promise<void> {
-    await apiClient.put('/kv', data);
+  // With expectedModRevision a concurrent change is rejected with a 409 EditConflict
+  async put(data: PutKeyReq): Promise<PutKeyResp> {
+    const response = await apiClient.put('/kv', data);
+    return response.data;
+  },
+
+  // Runs compares and then/else operations atomically; a failed compare is not an error
+  async txn(data: TxnReq): Promise<TxnResp> {
+    const response = await apiClient.post('/kv/txn', data);
+    return response.data;
   },
 
   async create(data: PutKeyReq): Promise<void> {
     await apiClient.post('/kv', data);
   },
 
+  // Validates and pretty-prints a value without storing it
+  async formatValue(data: FormatValueReq): Promise<FormatValueResp> {
+    const response = await apiClient.post('/kv/format', data);
+    return response.data;
+  },
+
   async deleteKey(connId: string, key: string): Promise<void> {
     await apiClient.delete('/kv', {
       params: { connId, key },
@@ -44,12 +80,14 @@ export const kvApi = {
     return response.data;
   },
 
-  async rename(data: RenameKeyReq): Promise<void> {
-    await apiClient.post('/kv/rename', data);
+  async rename(data: RenameKeyReq): Promise<BulkResp | void> {
+    const response = await apiClient.post('/kv/rename', data);
+    return response.data;
   },
 
-  async copy(data: CopyKeyReq): Promise<void> {
-    await apiClient.post('/kv/copy', data);
+  async copy(data: CopyKeyReq): Promise<BulkResp | void> {
+    const response = await apiClient.post('/kv/copy', data);
+    return response.data;
   },
 
   async getHistory(connId: string, key: string, limit = 10): Promise<GetHistoryResp> {
@@ -62,4 +100,53 @@ export const kvApi = {
   async rollback(data: RollbackReq): Promise<void> {
     await apiClient.post('/kv/rollback', data);
   },
+
+  // Opens a Server-Sent Events stream of put/delete events; close() the returned source to stop
+  watch(connId: string, key: string, prefix = false, rev?: number): EventSource {
+    const params = new URLSearchParams({ connId, key, prefix: String(prefix) });
+    if (rev) {
+      params.set('rev', String(rev));
+    }
+    return new EventSource(`${apiClient.defaults.baseURL}/kv/watch?${params.toString()}`);
+  },
+
+  // URL of a streamed export download; open it in a link or window to save the file
+  exportUrl(connId: string, prefix: string, format: ExportFormat = 'nested'): string {
+    const params = new URLSearchParams({ connId, prefix, format });
+    return `${apiClient.defaults.baseURL}/kv/export?${params.toString()}`;
+  },
+
+  // The dump is sent as the raw body; a 409 response carries the BulkResp of conflicts
+  async importKeys(connId: string, dump: Blob | string, options: ImportOptions = {}): Promise<BulkResp> {
+    const response = await apiClient.post('/kv/import', dump, {
+      params: { connId, ...options },
+      headers: { 'Content-Type': 'application/octet-stream' },
+    });
+    return response.data;
+  },
+
+  async diff(data: DiffReq): Promise<DiffResp> {
+    const response = await apiClient.post('/kv/diff', data);
+    return response.data;
+  },
+
+  // Applies the selected keys from source to target in one transaction
+  async sync(data: SyncReq): Promise<BulkResp> {
+    const response = await apiClient.post('/kv/diff/sync', data);
+    return response.data;
+  },
+
+  // Resolves an RFC 3339 time to a revision through the audit log
+  async revisionAt(connId: string, at: string): Promise<RevisionAtResp> {
+    const response = await apiClient.get('/kv/revision', {
+      params: { connId, at },
+    });
+    return response.data;
+  },
+
+  // dryRun returns the preview; otherwise the restore is applied in one transaction
+  async restore(data: RestoreReq): Promise<RestoreResp> {
+    const response = await apiClient.post('/kv/restore', data);
+    return response.data;
+  },
 };
@@ -0,0 +1,38 @@
+import apiClient from './client';
+import type { LeaseItem, ListLeasesResp } from '@/types/lease';
+
+export const leasesApi = {
+  async list(connId: string, details = false): Promise<ListLeasesResp> {
+    const response = await apiClient.get('/leases', {
+      params: { connId, details },
+    });
+    return response.data;
+  },
+
+  async get(connId: string, id: string): Promise<LeaseItem> {
+    const response = await apiClient.get('/leases/detail', {
+      params: { connId, id },
+    });
+    return response.data;
+  },
+
+  async grant(connId: string, ttl: number): Promise<LeaseItem> {
+    const response = await apiClient.post('/leases', { connId, ttl });
+    return response.data;
+  },
+
+  async revoke(connId: string, id: string): Promise<void> {
+    await apiClient.post('/leases/revoke', { connId, id });
+  },
+
+  async keepAlive(connId: string, id: string, periodic = false): Promise<LeaseItem> {
+    const response = await apiClient.post('/leases/keepalive', { connId, id, periodic });
+    return response.data;
+  },
+
+  async stopKeepAlive(connId: string, id: string): Promise<void> {
+    await apiClient.delete('/leases/keepalive', {
+      params: { connId, id },
+    });
+  },
+};
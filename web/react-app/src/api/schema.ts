@@ -0,0 +1,36 @@
+import apiClient from './client';
+import type {
+  SchemaBinding,
+  SchemaBindingReq,
+  UpdateSchemaBindingReq,
+  SchemaReportResp,
+} from '@/types/schema';
+
+export const schemaApi = {
+  async list(): Promise<SchemaBinding[]> {
+    const response = await apiClient.get('/schemas');
+    return response.data.bindings;
+  },
+
+  async add(data: SchemaBindingReq): Promise<SchemaBinding> {
+    const response = await apiClient.post('/schemas', data);
+    return response.data;
+  },
+
+  async update(data: UpdateSchemaBindingReq): Promise<SchemaBinding> {
+    const response = await apiClient.put('/schemas', data);
+    return response.data;
+  },
+
+  async remove(id: string): Promise<void> {
+    await apiClient.delete('/schemas', { params: { id } });
+  },
+
+  // Validates every key under prefix against the schemas bound to it
+  async report(connId: string, prefix: string): Promise<SchemaReportResp> {
+    const response = await apiClient.get('/kv/schema-report', {
+      params: { connId, prefix },
+    });
+    return response.data;
+  },
+};
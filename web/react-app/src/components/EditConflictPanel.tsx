@@ -0,0 +1,84 @@
+import React, { useRef } from 'react';
+import { DiffEditor, type DiffOnMount } from '@monaco-editor/react';
+import { Alert, Button, Space, Typography } from 'antd';
+import type { EditConflict } from '@/types/kv';
+
+const { Text } = Typography;
+
+interface EditConflictPanelProps {
+  conflict: EditConflict;
+  // Content the edit started from, the common base of both sides
+  base: string;
+  mine: string;
+  language: string;
+  onResolve: (merged: string) => void;
+  onDiscardMine: () => void;
+}
+
+// Shows the value saved by someone else next to the local edits. The right
+// side is editable and becomes the merged value.
+export const EditConflictPanel: React.FC<EditConflictPanelProps> = ({
+  conflict,
+  base,
+  mine,
+  language,
+  onResolve,
+  onDiscardMine,
+}) => {
+  const diffRef = useRef<Parameters<DiffOnMount>[0] | null>(null);
+
+  const handleResolve = () => {
+    const merged = diffRef.current?.getModifiedEditor().getValue() ?? mine;
+    onResolve(merged);
+  };
+
+  const description = conflict.exists
+    ? `Revision ${conflict.modRevision} was saved after you started editing. Left: current value, right: your edits. Merge into the right side, then save again.`
+    : 'The key was deleted after you started editing. Saving again recreates it with your edits.';
+
+  return (
+    <div style={{ marginBottom: 12 }}>
+      <Alert
+        type="warning"
+        showIcon
+        message="This key was changed by someone else"
+        description={description}
+        action={
+          <Space direction="vertical">
+            <Button size="small" type="primary" onClick={handleResolve}>
+              Use merged
+            </Button>
+            <Button size="small" onClick={onDiscardMine} disabled={!conflict.exists}>
+              Discard mine
+            </Button>
+          </Space>
+        }
+      />
+      {conflict.exists && (
+        <div style={{ marginTop: 8, border: '1px solid #d9d9d9', borderRadius: 4 }}>
+          <DiffEditor
+            height="300px"
+            language={language}
+            original={conflict.value || ''}
+            modified={mine}
+            onMount={(e) => {
+              diffRef.current = e;
+            }}
+            options={{
+              readOnly: false,
+              originalEditable: false,
+              renderSideBySide: true,
+              minimap: { enabled: false },
+              automaticLayout: true,
+            }}
+          />
+          {base !== conflict.value && base !== mine && (
+            <Text type="secondary" style={{ fontSize: 12, padding: 8, display: 'block' }}>
+              Both sides changed the value loaded at revision {conflict.expectedModRevision}.
+            </Text>
+          )}
+        </div>
+      )}
+    </div>
+  );
+};
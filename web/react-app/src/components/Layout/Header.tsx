@@ -1,8 +1,10 @@
-import React from 'react';
-import { Layout as AntLayout, Menu, Select, Space, Typography } from 'antd';
+import React, { useEffect, useState } from 'react';
+import { Layout as AntLayout, Button, Menu, Select, Space, Typography } from 'antd';
 import { useLocation, useNavigate } from 'react-router-dom';
 import { useConnectionStore } from '@/store/connectionStore';
-import { DatabaseOutlined } from '@ant-design/icons';
+import { DatabaseOutlined, LogoutOutlined } from '@ant-design/icons';
+import { authApi } from '@/api';
+import type { MeResp } from '@/types/auth';
 
 const { Header: AntHeader } = AntLayout;
 const { Option } = Select;
@@ -12,8 +14,18 @@ export const Header: React.FC = () => {
   const navigate = useNavigate();
   const location = useLocation();
   const { connections, currentConnectionId, setCurrentConnection } = useConnectionStore();
+  const [me, setMe] = useState<MeResp | null>(null);
 
-  const connectedConnections = connections.filter((c) => c.status === 'connected');
+  useEffect(() => {
+    authApi.me().then(setMe).catch(() => setMe(null));
+  }, []);
+
+  const handleLogout = async () => {
+    await authApi.logout();
+    window.location.assign('/login');
+  };
+
+  const connectedConnections = connections.filter((c) => c.status === 'connected' || c.status === 'degraded');
   const currentConnection = connections.find((c) => c.id === currentConnectionId);
 
   const menuItems = [
@@ -69,6 +81,16 @@ export const Header: React.FC = () => {
             {currentConnection.endpoints.join(', ')}
           </Text>
         )}
+        {me?.authEnabled && (
+          <>
+            <Text style={{ color: 'rgba(255,255,255,0.65)' }}>
+              {me.user.username} ({me.user.role})
+            </Text>
+            <Button type="text" icon={<LogoutOutlined />} onClick={handleLogout} style={{ color: 'white' }}>
+              Logout
+            </Button>
+          </>
+        )}
       </Space>
     </AntHeader>
   );
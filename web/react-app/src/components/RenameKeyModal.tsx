@@ -27,6 +27,7 @@ export const RenameKeyModal: React.FC<RenameKeyModalProps> = ({
       form.setFieldsValue({
         newKey: currentKey,
         overwrite: false,
+        recursive: false,
       });
     }
   }, [open, currentKey, form]);
@@ -53,6 +54,7 @@ export const RenameKeyModal: React.FC<RenameKeyModalProps> = ({
         from: currentKey,
         to: newKey,
         overwrite: values.overwrite || false,
+        recursive: values.recursive || false,
       });
 
       message.success('Key renamed successfully');
@@ -113,9 +115,15 @@ export const RenameKeyModal: React.FC<RenameKeyModalProps> = ({
           </Checkbox>
         </Form.Item>
 
+        <Form.Item name="recursive" valuePropName="checked">
+          <Checkbox>
+            Move all keys under this directory
+          </Checkbox>
+        </Form.Item>
+
         <Text type="secondary" style={{ fontSize: 12 }}>
           Note: Renaming creates a copy at the new location and deletes the original.
-          The operation is atomic.
+          Single-key renames are atomic; directory moves run in chunked transactions.
         </Text>
       </Form>
     </Modal>
@@ -42,8 +42,11 @@ export const VersionHistoryPanel: React.FC<VersionHistoryPanelProps> = ({ onClos
     }
   };
 
-  const formatTimestamp = (createTime: number) => {
-    return new Date(createTime * 1000).toLocaleString();
+  // Times are only known for changes made through the manager
+  const formatTimestamp = (rev: KeyRevision) => {
+    if (!rev.time) return `Rev ${rev.modRevision}`;
+    const time = new Date(rev.time).toLocaleString();
+    return rev.user ? `${time} · ${rev.user}` : time;
   };
 
   const truncateValue = (value: string, maxLength = 100) => {
@@ -145,7 +148,7 @@ export const VersionHistoryPanel: React.FC<VersionHistoryPanelProps> = ({ onClos
                     }}
                   >
                     <Text type="secondary" style={{ fontSize: 11 }}>
-                      {formatTimestamp(rev.createTime)}
+                      {formatTimestamp(rev)}
                     </Text>
 
                     {index !== 0 && (
@@ -1,4 +1,4 @@
-import React from 'react';
+import React, { useEffect } from 'react';
 import { List, Button, Tag, Popconfirm, Space, Card, Typography } from 'antd';
 import { useConnectionStore } from '@/store/connectionStore';
 import { formatTimestamp } from '@/utils/format';
@@ -6,6 +6,7 @@ import {
   CheckCircleOutlined,
   CloseCircleOutlined,
   ExclamationCircleOutlined,
+  WarningOutlined,
   DeleteOutlined,
   LinkOutlined,
   DisconnectOutlined,
@@ -13,14 +14,24 @@ import {
 
 const { Text } = Typography;
 
+const STATUS_POLL_INTERVAL = 10000;
+
 interface ConnectionListProps {
   // Reserved for future use
 }
 
 export const ConnectionList: React.FC<ConnectionListProps> = () => {
-  const { connections, connectToEtcd, disconnectFromEtcd, deleteConnection } =
+  const { connections, fetchConnections, connectToEtcd, disconnectFromEtcd, deleteConnection } =
     useConnectionStore();
 
+  // The server probes connections in the background; poll to show their status live
+  useEffect(() => {
+    const timer = window.setInterval(() => {
+      fetchConnections().catch(() => {});
+    }, STATUS_POLL_INTERVAL);
+    return () => window.clearInterval(timer);
+  }, [fetchConnections]);
+
   const getStatusConfig = (status: string) => {
     switch (status) {
       case 'connected':
@@ -29,6 +40,12 @@ export const ConnectionList: React.FC<ConnectionListProps> = () => {
           icon: <CheckCircleOutlined />,
           text: 'Connected',
         };
+      case 'degraded':
+        return {
+          color: 'warning',
+          icon: <WarningOutlined />,
+          text: 'Degraded',
+        };
       case 'error':
         return {
           color: 'error',
@@ -87,22 +104,24 @@ export const ConnectionList: React.FC<ConnectionListProps> = () => {
         return (
           <List.Item
             actions={[
-              conn.status === 'connected' ? (
-                <Button
-                  key="disconnect"
-                  icon={<DisconnectOutlined />}
-                  onClick={() => handleDisconnect(conn.id)}
-                >
-                  Disconnect
-                </Button>
-              ) : (
+              // Failed connections are retried in the background until disconnected
+              conn.status !== 'connected' && conn.status !== 'degraded' && (
                 <Button
                   key="connect"
                   type="primary"
                   icon={<LinkOutlined />}
                   onClick={() => handleConnect(conn.id)}
                 >
-                  Connect
+                  {conn.status === 'error' ? 'Retry' : 'Connect'}
+                </Button>
+              ),
+              conn.status !== 'disconnected' && (
+                <Button
+                  key="disconnect"
+                  icon={<DisconnectOutlined />}
+                  onClick={() => handleDisconnect(conn.id)}
+                >
+                  Disconnect
                 </Button>
               ),
               <Popconfirm
@@ -118,7 +137,7 @@ export const ConnectionList: React.FC<ConnectionListProps> = () => {
                   Delete
                 </Button>
               </Popconfirm>,
-            ]}
+            ].filter(Boolean)}
           >
             <List.Item.Meta
               title={
@@ -127,6 +146,10 @@ export const ConnectionList: React.FC<ConnectionListProps> = () => {
                   <Tag color={statusConfig.color} icon={statusConfig.icon}>
                     {statusConfig.text}
                   </Tag>
+                  {conn.readOnly && <Tag color="orange">read-only</Tag>}
+                  {conn.protectedPrefixes.length > 0 && (
+                    <Tag color="gold">{conn.protectedPrefixes.length} protected</Tag>
+                  )}
                 </Space>
               }
               description={
@@ -142,6 +165,19 @@ export const ConnectionList: React.FC<ConnectionListProps> = () => {
                   <Text type="secondary">
                     <strong>Updated:</strong> {formatTimestamp(conn.updatedAt)}
                   </Text>
+                  {conn.lastError && (
+                    <Text type={conn.status === 'error' ? 'danger' : 'warning'}>
+                      <strong>Last error:</strong> {conn.lastError}
+                      {conn.nextProbeAt &&
+                        ` (retry ${conn.failures} at ${new Date(conn.nextProbeAt).toLocaleTimeString()})`}
+                    </Text>
+                  )}
+                  {conn.lastHealthyAt && (
+                    <Text type="secondary">
+                      <strong>Last healthy:</strong> {new Date(conn.lastHealthyAt).toLocaleString()}
+                      {conn.status !== 'error' && ` (${conn.latencyMs ?? 0} ms)`}
+                    </Text>
+                  )}
                 </Space>
               }
             />
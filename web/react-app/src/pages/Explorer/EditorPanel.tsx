@@ -1,10 +1,11 @@
 import React, { useState } from 'react';
 import Editor from '@monaco-editor/react';
-import { Button, Space, Select, Typography, Spin, Popconfirm, Empty } from 'antd';
+import { Button, Space, Select, Typography, Spin, Popconfirm, Empty, Tag } from 'antd';
 import { useEditorStore } from '@/store/editorStore';
 import { useConnectionStore } from '@/store/connectionStore';
 import { SaveOutlined, DeleteOutlined, UndoOutlined, HistoryOutlined } from '@ant-design/icons';
 import { VersionHistoryPanel } from '@/components/VersionHistoryPanel';
+import { EditConflictPanel } from '@/components/EditConflictPanel';
 
 const { Text } = Typography;
 const { Option } = Select;
@@ -13,12 +14,17 @@ export const EditorPanel: React.FC = () => {
   const {
     currentKey,
     content,
+    originalContent,
     isDirty,
     format,
+    encoding,
+    conflict,
     loading,
     setContent,
     setFormat,
     saveKey,
+    resolveConflict,
+    discardMine,
     deleteKey,
     reset,
   } = useEditorStore();
@@ -56,6 +62,9 @@ export const EditorPanel: React.FC = () => {
         return 'json';
       case 'yaml':
         return 'yaml';
+      case 'toml':
+      case 'properties':
+        return 'ini';
       default:
         return 'plaintext';
     }
@@ -123,12 +132,20 @@ export const EditorPanel: React.FC = () => {
               </Button>
             </Popconfirm>
 
-            <Select value={format} onChange={setFormat} style={{ width: 100 }}>
+            <Select value={format} onChange={setFormat} style={{ width: 120 }}>
               <Option value="json">JSON</Option>
               <Option value="yaml">YAML</Option>
+              <Option value="toml">TOML</Option>
+              <Option value="properties">Properties</Option>
               <Option value="text">Text</Option>
             </Select>
 
+            {encoding === 'base64' && (
+              <Tag color="orange" title="Binary value, shown and saved as base64">
+                base64
+              </Tag>
+            )}
+
             <Button
               icon={<HistoryOutlined />}
               onClick={() => setHistoryPanelVisible(!historyPanelVisible)}
@@ -145,6 +162,17 @@ export const EditorPanel: React.FC = () => {
           </Space>
         </Space>
 
+        {conflict && (
+          <EditConflictPanel
+            conflict={conflict}
+            base={originalContent}
+            mine={content}
+            language={getLanguage()}
+            onResolve={resolveConflict}
+            onDiscardMine={discardMine}
+          />
+        )}
+
         <Spin spinning={loading}>
           <div style={{ flex: 1, minHeight: 600, border: '1px solid #d9d9d9', borderRadius: 4 }}>
             <Editor
@@ -0,0 +1,43 @@
+import React, { useState } from 'react';
+import { Button, Card, Form, Input, Typography } from 'antd';
+import { LockOutlined, UserOutlined } from '@ant-design/icons';
+import { authApi } from '@/api';
+
+const { Title } = Typography;
+
+export const Login: React.FC = () => {
+  const [loading, setLoading] = useState(false);
+
+  const handleSubmit = async (values: { username: string; password: string }) => {
+    setLoading(true);
+    try {
+      await authApi.login(values.username, values.password);
+      window.location.assign('/connections');
+    } catch {
+      // Error message shown by the API client
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div style={{ display: 'flex', justifyContent: 'center', paddingTop: 120 }}>
+      <Card style={{ width: 360 }}>
+        <Title level={3} style={{ textAlign: 'center' }}>
+          etcd-manager
+        </Title>
+        <Form layout="vertical" onFinish={handleSubmit}>
+          <Form.Item name="username" rules={[{ required: true, message: 'Please enter username' }]}>
+            <Input prefix={<UserOutlined />} placeholder="Username" autoComplete="username" />
+          </Form.Item>
+          <Form.Item name="password" rules={[{ required: true, message: 'Please enter password' }]}>
+            <Input.Password prefix={<LockOutlined />} placeholder="Password" autoComplete="current-password" />
+          </Form.Item>
+          <Button type="primary" htmlType="submit" block loading={loading}>
+            Log in
+          </Button>
+        </Form>
+      </Card>
+    </div>
+  );
+};
@@ -1,9 +1,9 @@
 import { create } from 'zustand';
 import { kvApi } from '@/api';
 import { message } from 'antd';
-import type { KeyRevision } from '@/types/kv';
+import type { EditConflict, KeyRevision, ValueEncoding, ValueFormat } from '@/types/kv';
 
-type ContentFormat = 'json' | 'yaml' | 'text';
+export type ContentFormat = ValueFormat | 'text';
 
 interface EditorState {
   currentKey: string | null;
@@ -11,6 +11,12 @@ interface EditorState {
   originalContent: string;
   isDirty: boolean;
   format: ContentFormat;
+  // Binary values are edited as base64 and written back as such
+  encoding: ValueEncoding;
+  // Revision the content was loaded at; saves fail if the key changed since
+  modRevision: number;
+  // Set when a save lost a race; originalContent is the common base
+  conflict: EditConflict | null;
   ttl: number;
   loading: boolean;
   history: KeyRevision[];
@@ -19,33 +25,23 @@ interface EditorState {
   setContent: (content: string) => void;
   setFormat: (format: ContentFormat) => void;
   saveKey: (connId: string) => Promise<void>;
+  resolveConflict: (merged: string) => void;
+  discardMine: () => void;
   deleteKey: (connId: string, key: string) => Promise<void>;
   loadHistory: (connId: string, key: string) => Promise<void>;
   rollbackToRevision: (connId: string, key: string, revision: number) => Promise<void>;
   reset: () => void;
 }
 
-const detectFormat = (content: string): ContentFormat => {
-  if (!content || content.trim() === '') return 'text';
-
-  try {
-    JSON.parse(content);
-    return 'json';
-  } catch {
-    // Check for YAML patterns
-    if (content.includes(':') && (content.includes('\n') || content.includes('- '))) {
-      return 'yaml';
-    }
-    return 'text';
-  }
-};
-
 export const useEditorStore = create<EditorState>((set, get) => ({
   currentKey: null,
   content: '',
   originalContent: '',
   isDirty: false,
   format: 'text',
+  encoding: 'utf8',
+  modRevision: 0,
+  conflict: null,
   ttl: 0,
   loading: false,
   history: [],
@@ -67,7 +63,7 @@ export const useEditorStore = create<EditorState>((set, get) => ({
     try {
       const data = await kvApi.get(connId, key);
       const content = data.value || '';
-      const format = detectFormat(content);
+      const format: ContentFormat = data.format || 'text';
 
       set({
         currentKey: key,
@@ -75,6 +71,9 @@ export const useEditorStore = create<EditorState>((set, get) => ({
         originalContent: content,
         isDirty: false,
         format,
+        encoding: data.encoding || 'utf8',
+        modRevision: data.modRevision || 0,
+        conflict: null,
         ttl: data.ttl,
         loading: false,
       });
@@ -105,25 +104,67 @@ export const useEditorStore = create<EditorState>((set, get) => ({
     }
 
     try {
-      await kvApi.put({
+      const resp = await kvApi.put({
         connId,
         key: state.currentKey,
         value: state.content,
         ttl: state.ttl > 0 ? state.ttl : undefined,
+        // The server rejects values that do not parse, with the error position
+        format: state.format === 'text' ? undefined : state.format,
+        encoding: state.encoding,
+        expectedModRevision: state.modRevision || undefined,
       });
 
       set({
         originalContent: state.content,
         isDirty: false,
+        modRevision: resp.modRevision,
+        conflict: null,
       });
 
       message.success('Key saved successfully');
-    } catch (error) {
+    } catch (error: any) {
+      if (error.response?.status === 409) {
+        set({ conflict: error.response.data as EditConflict });
+      }
       console.error('Failed to save key:', error);
       throw error;
     }
   },
 
+  // Takes the merged content and retries against the conflicting revision
+  resolveConflict: (merged: string) => {
+    const { conflict } = get();
+    if (!conflict) {
+      return;
+    }
+    set({
+      content: merged,
+      originalContent: conflict.value || '',
+      isDirty: true,
+      encoding: conflict.encoding || 'utf8',
+      modRevision: conflict.modRevision || 0,
+      conflict: null,
+    });
+  },
+
+  // Drops local edits in favour of the current value
+  discardMine: () => {
+    const { conflict } = get();
+    if (!conflict) {
+      return;
+    }
+    const content = conflict.value || '';
+    set({
+      content,
+      originalContent: content,
+      isDirty: false,
+      encoding: conflict.encoding || 'utf8',
+      modRevision: conflict.modRevision || 0,
+      conflict: null,
+    });
+  },
+
   deleteKey: async (connId: string, key: string) => {
     try {
       await kvApi.deleteKey(connId, key);
@@ -176,6 +217,9 @@ export const useEditorStore = create<EditorState>((set, get) => ({
       originalContent: '',
       isDirty: false,
       format: 'text',
+      encoding: 'utf8',
+      modRevision: 0,
+      conflict: null,
       ttl: 0,
       loading: false,
       history: [],
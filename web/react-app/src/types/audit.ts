@@ -0,0 +1,28 @@
+export interface AuditEntry {
+  time: string;
+  user?: string;
+  remoteAddr: string;
+  connId?: string;
+  operation: string;
+  keys?: string[];
+  count?: number;
+  target?: string;
+  oldHash?: string;
+  newHash?: string;
+  revision?: number;
+}
+
+export interface AuditQuery {
+  connId?: string;
+  prefix?: string;
+  operation?: string;
+  // RFC 3339 timestamps; until is exclusive
+  since?: string;
+  until?: string;
+  limit?: number;
+}
+
+export interface AuditResp {
+  entries: AuditEntry[];
+  enabled: boolean;
+}
@@ -0,0 +1,31 @@
+export type Role = 'viewer' | 'editor' | 'admin';
+
+export interface User {
+  username: string;
+  role: Role;
+  createdAt?: number;
+  updatedAt?: number;
+}
+
+export interface LoginResp {
+  token: string;
+  expiresAt: number;
+  user: User;
+}
+
+export interface MeResp {
+  user: User;
+  authEnabled: boolean;
+}
+
+export interface AddUserReq {
+  username: string;
+  password: string;
+  role: Role;
+}
+
+export interface UpdateUserReq {
+  username: string;
+  password?: string;
+  role?: Role;
+}
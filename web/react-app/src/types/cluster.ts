@@ -0,0 +1,89 @@
+export interface MemberItem {
+  id: string;
+  name: string;
+  peerURLs: string[];
+  clientURLs: string[] | null;
+  isLearner: boolean;
+  isLeader: boolean;
+}
+
+export interface EndpointStatus {
+  endpoint: string;
+  memberId?: string;
+  version?: string;
+  dbSize: number;
+  dbSizeInUse: number;
+  leader?: string;
+  raftIndex: number;
+  raftTerm: number;
+  raftAppliedIndex: number;
+  isLearner: boolean;
+  errors?: string[];
+  error?: string;
+}
+
+export interface AlarmItem {
+  memberId: string;
+  alarm: string;
+}
+
+export interface ClusterResp {
+  clusterId: string;
+  leader?: string;
+  members: MemberItem[];
+  endpoints: EndpointStatus[];
+  alarms: AlarmItem[];
+}
+
+export interface MaintenanceStats {
+  revision: number;
+  endpoints: EndpointStatus[];
+}
+
+export interface DefragmentResult {
+  endpoint: string;
+  durationMs: number;
+  error?: string;
+}
+
+// Without confirmToken the request is a preview returning a single-use token
+export interface CompactReq {
+  connId: string;
+  revision?: number;
+  physical?: boolean;
+  confirmToken?: string;
+}
+
+export interface DefragmentReq {
+  connId: string;
+  endpoints?: string[];
+  confirmToken?: string;
+}
+
+export interface MaintenanceResp {
+  operation: 'compact' | 'defragment';
+  applied: boolean;
+  confirmToken?: string;
+  expiresAt?: string;
+  revision?: number;
+  physical?: boolean;
+  endpoints?: string[];
+  before: MaintenanceStats;
+  after?: MaintenanceStats;
+  results?: DefragmentResult[];
+}
+
+export interface BackupEntry {
+  connId: string;
+  connName: string;
+  // Relative to DataPath/backups
+  file: string;
+  time: string;
+  size: number;
+  sha256: string;
+}
+
+export interface BackupListResp {
+  entries: BackupEntry[];
+  enabled: boolean;
+}
@@ -1,11 +1,54 @@
+export interface TLSConfig {
+  caCert?: string;
+  // File references are paths relative to DataPath/certs on the server
+  caCertFile?: string;
+  clientCert?: string;
+  clientCertFile?: string;
+  clientKey?: string;
+  clientKeyFile?: string;
+  serverName?: string;
+  insecureSkipVerify?: boolean;
+}
+
+// TLS settings as returned by the API; the inline client key is masked
+export interface TLSInfo extends Omit<TLSConfig, 'clientKey'> {
+  hasClientKey: boolean;
+}
+
 export interface Connection {
   id: string;
   name: string;
   endpoints: string[];
   username?: string;
-  password?: string;
-  status: 'connected' | 'disconnected' | 'error';
+  hasPassword: boolean;
+  tls?: TLSInfo;
+  readOnly: boolean;
+  protectedPrefixes: string[];
+  // degraded: usable, but an endpoint is down, an alarm is raised or reads are slow
+  status: 'connected' | 'degraded' | 'disconnected' | 'error';
   updatedAt: number;
+  // Set while the connection is monitored, from its latest probes
+  lastError?: string;
+  lastProbeAt?: string;
+  lastHealthyAt?: string;
+  latencyMs?: number;
+  // Consecutive failures; the next reconnect is attempted at nextProbeAt
+  failures?: number;
+  nextProbeAt?: string;
+  health?: EndpointHealth[];
+}
+
+export interface EndpointHealth {
+  endpoint: string;
+  healthy: boolean;
+  latencyMs: number;
+  error?: string;
+}
+
+// Guards against accidental writes through the manager
+export interface ConnectionProtection {
+  readOnly?: boolean;
+  protectedPrefixes?: string[];
 }
 
 export interface AddConnectionReq {
@@ -13,6 +56,8 @@ export interface AddConnectionReq {
   endpoints: string[];
   username?: string;
   password?: string;
+  tls?: TLSConfig;
+  protection?: ConnectionProtection;
 }
 
 export interface UpdateConnectionReq {
@@ -21,4 +66,8 @@ export interface UpdateConnectionReq {
   endpoints?: string[];
   username?: string;
   password?: string;
+  // Remove the stored password; an empty password leaves it unchanged
+  clearPassword?: boolean;
+  tls?: TLSConfig;
+  protection?: ConnectionProtection;
 }
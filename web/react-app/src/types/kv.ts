@@ -1,13 +1,31 @@
+// Structured value formats validated by the server
+export type ValueFormat = 'json' | 'yaml' | 'toml' | 'properties';
+
+// Values that are not valid UTF-8, such as protobuf, travel as base64
+export type ValueEncoding = 'utf8' | 'base64';
+
 export interface KeyItem {
   key: string;
   value?: string;
   isDir: boolean;
   ttl: number;
+  lease?: string;
+  // Detected by the server for values, except in keys-only paged listings
+  format?: ValueFormat;
+  // Set for values; omitted for directories
+  encoding?: ValueEncoding;
+  // Set when fetching a single key
+  modRevision?: number;
+  version?: number;
 }
 
 export interface ListKeysResp {
   prefix: string;
   children: KeyItem[];
+  hasMore?: boolean;
+  cursor?: string;
+  // Set when listing a past revision
+  revision?: number;
 }
 
 export interface GetKeyReq {
@@ -20,12 +38,39 @@ export interface PutKeyReq {
   key: string;
   value: string;
   ttl?: number;
+  lease?: string;
+  // Rejects values that do not parse as this format
+  format?: ValueFormat;
+  // Defaults to utf8; send base64 to write binary values unchanged
+  encoding?: ValueEncoding;
+  // Fails with 409 and an EditConflict if the key changed since this revision
+  expectedModRevision?: number;
+}
+
+export interface PutKeyResp {
+  modRevision: number;
+}
+
+// Body of the 409 returned for a stale expectedModRevision
+export interface EditConflict {
+  code: number;
+  message: string;
+  key: string;
+  expectedModRevision: number;
+  // False when the key was deleted in the meantime
+  exists: boolean;
+  value?: string;
+  encoding?: ValueEncoding;
+  modRevision?: number;
+  version?: number;
 }
 
 export interface ListKeysReq {
   connId: string;
   prefix: string;
   includeTTL?: boolean;
+  limit?: number;
+  cursor?: string;
 }
 
 export interface RenameKeyReq {
@@ -33,6 +78,26 @@ export interface RenameKeyReq {
   from: string;
   to: string;
   overwrite?: boolean;
+  recursive?: boolean;
+}
+
+export interface BulkKeyResult {
+  from: string;
+  to: string;
+  action?: 'create' | 'update' | 'unchanged' | 'skip' | 'conflict';
+  status: string;
+  error?: string;
+}
+
+export interface BulkResp {
+  dryRun?: boolean;
+  total: number;
+  succeeded: number;
+  failed: number;
+  complete: boolean;
+  // Revision of the last committed chunk
+  revision?: number;
+  results: BulkKeyResult[];
 }
 
 export interface CopyKeyReq {
@@ -40,6 +105,11 @@ export interface CopyKeyReq {
   from: string;
   to: string;
   overwrite?: boolean;
+  recursive?: boolean;
+  targetConnId?: string;
+  conflict?: 'overwrite' | 'skip' | 'fail';
+  preserveLease?: boolean;
+  dryRun?: boolean;
 }
 
 export interface BatchDeleteReq {
@@ -50,15 +120,24 @@ export interface BatchDeleteReq {
 export interface KeyRevision {
   revision: number;
   value: string;
+  encoding: ValueEncoding;
   modRevision: number;
+  // Create revision of the key
   createTime: number;
   version: number;
+  // From the audit log, for changes made through the manager
+  time?: string;
+  user?: string;
 }
 
 export interface GetHistoryResp {
   key: string;
+  // Newest first
   history: KeyRevision[];
-  note: string;
+  // Set when older versions were compacted away
+  compactRevision?: number;
+  complete: boolean;
+  note?: string;
 }
 
 export interface RollbackReq {
@@ -66,3 +145,193 @@ export interface RollbackReq {
   key: string;
   revision: number;
 }
+
+export interface WatchEvent {
+  type: 'put' | 'delete';
+  key: string;
+  value?: string;
+  prevValue?: string;
+  encoding?: ValueEncoding;
+  prevEncoding?: ValueEncoding;
+  hasPrev: boolean;
+  revision: number;
+  createRevision: number;
+  version: number;
+  lease?: string;
+}
+
+// nested/yaml: tree by '/', flat: key -> value, jsonl: etcdctl-style base64 lines with metadata
+export type ExportFormat = 'nested' | 'flat' | 'yaml' | 'jsonl';
+
+export interface ImportOptions {
+  // Directory the dump is placed under; for nested/yaml it is the tree root
+  prefix?: string;
+  format?: ExportFormat;
+  // Removed from flat/jsonl keys before prefix is added
+  stripPrefix?: string;
+  conflict?: 'overwrite' | 'skip' | 'fail';
+  dryRun?: boolean;
+}
+
+export interface DiffSide {
+  connId: string;
+  prefix: string;
+}
+
+export interface DiffReq {
+  left: DiffSide;
+  right: DiffSide;
+  // Glob patterns on keys relative to the prefixes; a matching directory ignores its subtree
+  ignore?: string[];
+  limit?: number;
+}
+
+export interface DiffItem {
+  // Relative to the prefixes
+  key: string;
+  leftValue?: string;
+  rightValue?: string;
+  leftEncoding?: ValueEncoding;
+  rightEncoding?: ValueEncoding;
+  leftModRevision?: number;
+  rightModRevision?: number;
+  // Unified line diff from left to right, for changed text values
+  diff?: string;
+}
+
+export interface DiffResp {
+  left: DiffSide & { revision: number };
+  right: DiffSide & { revision: number };
+  counts: {
+    onlyLeft: number;
+    onlyRight: number;
+    changed: number;
+    unchanged: number;
+    ignored: number;
+  };
+  onlyLeft: DiffItem[];
+  onlyRight: DiffItem[];
+  changed: DiffItem[];
+  truncated: boolean;
+}
+
+export interface SyncReq {
+  source: DiffSide;
+  target: DiffSide;
+  keys: string[];
+  // Revision of the target side in the diff; keys changed after it are refused
+  targetRevision?: number;
+}
+
+export interface RevisionAtResp {
+  revision: number;
+  // Audit entry the timestamp was resolved through
+  time: string;
+  operation: string;
+}
+
+export interface RestoreReq {
+  connId: string;
+  key: string;
+  revision: number;
+  dryRun?: boolean;
+  // readRevision of the preview; the restore fails with 409 if the subtree changed since
+  expectedReadRevision?: number;
+}
+
+// Items carry full keys; left is the current value, right the value at revision
+export interface RestoreResp {
+  dryRun?: boolean;
+  key: string;
+  revision: number;
+  readRevision: number;
+  created: DiffItem[];
+  updated: DiffItem[];
+  deleted: DiffItem[];
+  unchanged: number;
+  commitRevision?: number;
+}
+
+export interface FormatValueReq {
+  value: string;
+  // Defaults to the detected format
+  format?: ValueFormat;
+}
+
+export interface FormatValueResp {
+  // Empty when no structured format was detected
+  format: ValueFormat | '';
+  value: string;
+}
+
+// Body of a 400 for a value that does not parse as the requested format
+export interface ValueFormatError {
+  code: number;
+  message: string;
+  format: ValueFormat;
+  line?: number;
+  column?: number;
+}
+
+export type TxnCompareTarget = 'value' | 'version' | 'create' | 'mod' | 'lease';
+
+export interface TxnCompare {
+  key: string;
+  target: TxnCompareTarget;
+  result: '=' | '!=' | '<' | '>';
+  // For value; for lease a hex lease id, '0' for none
+  value?: string;
+  encoding?: ValueEncoding;
+  // For version, create and mod
+  number?: number;
+  // Compares every key starting with key
+  prefix?: boolean;
+}
+
+export interface TxnOp {
+  type: 'put' | 'delete' | 'delete-prefix' | 'get';
+  key: string;
+  value?: string;
+  encoding?: ValueEncoding;
+  // Hex lease id for put
+  lease?: string;
+  // get returns every key starting with key
+  prefix?: boolean;
+}
+
+export interface TxnReq {
+  connId: string;
+  compare?: TxnCompare[];
+  then?: TxnOp[];
+  else?: TxnOp[];
+}
+
+export interface TxnKV {
+  key: string;
+  value: string;
+  encoding: ValueEncoding;
+  createRevision: number;
+  modRevision: number;
+  version: number;
+  lease?: string;
+}
+
+export interface TxnOpResult {
+  type: TxnOp['type'];
+  key: string;
+  // Value replaced by a put or removed by a delete
+  prev?: TxnKV;
+  deleted?: number;
+  // Set for get; more means kvs was cut at the limit
+  kvs?: TxnKV[];
+  count?: number;
+  more?: boolean;
+}
+
+export interface TxnResp {
+  // True when every compare held and then ran
+  succeeded: boolean;
+  revision: number;
+  // One per operation of the branch that ran
+  results: TxnOpResult[];
+}
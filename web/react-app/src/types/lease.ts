@@ -0,0 +1,13 @@
+export interface LeaseItem {
+  id: string;
+  ttl: number;
+  grantedTTL: number;
+  keys?: string[];
+  keyCount: number;
+  keepAlive: boolean;
+}
+
+export interface ListLeasesResp {
+  leases: LeaseItem[];
+  truncated: boolean;
+}
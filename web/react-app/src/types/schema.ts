@@ -0,0 +1,52 @@
+export interface SchemaBinding {
+  id: string;
+  // Key prefix whose segments may be globs, e.g. /services/*/config
+  pattern: string;
+  // Limits the binding to one connection; omitted applies to all
+  connId?: string;
+  description?: string;
+  schema: Record<string, unknown> | boolean;
+  updatedAt: number;
+}
+
+export interface SchemaBindingReq {
+  pattern: string;
+  connId?: string;
+  description?: string;
+  // The JSON Schema document as text
+  schema: string;
+}
+
+export interface UpdateSchemaBindingReq extends SchemaBindingReq {
+  id: string;
+}
+
+export interface SchemaViolation {
+  bindingId: string;
+  pattern: string;
+  // JSON pointers into the value and the schema
+  path: string;
+  keyword: string;
+  message: string;
+}
+
+// Element of details in the 400 returned when a write fails validation
+export interface KeyViolation extends SchemaViolation {
+  key: string;
+}
+
+export interface SchemaReportItem {
+  key: string;
+  violations: SchemaViolation[];
+}
+
+export interface SchemaReportResp {
+  prefix: string;
+  revision: number;
+  // Keys covered by a schema; unbound keys are counted separately
+  checked: number;
+  valid: number;
+  unbound: number;
+  invalid: SchemaReportItem[];
+  truncated?: boolean;
+}